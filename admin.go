@@ -0,0 +1,395 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TTL returns the remaining time to live of the session with the given
+// ID, or ErrSessionNotFound if it doesn't exist.
+func (s *RediStore) TTL(id string) (time.Duration, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	ms, err := redis.Int64(conn.Do("PTTL", s.sessionKey(id)))
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case ms == -2:
+		return 0, ErrSessionNotFound
+	case ms < 0:
+		// No expiry; sessions are always written with one.
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// DeleteByID deletes the session with the given ID, along with its index
+// entries and metadata, without a request or cookie. It returns
+// ErrSessionNotFound if the session doesn't exist.
+//
+// Sessions whose payload cannot be deserialized, such as gob values of
+// types this program doesn't register, are deleted too; their user index
+// entries are pruned lazily.
+func (s *RediStore) DeleteByID(id string) error {
+	session := s.newStoredSession(id)
+	ok, err := s.load(context.Background(), session)
+	if !ok {
+		if err != nil {
+			return err
+		}
+		return ErrSessionNotFound
+	}
+	return s.delete(context.Background(), session)
+}
+
+// Purge deletes every session whose ID starts with idPrefix, walking the
+// keyspace with SCAN, and returns the number of sessions deleted. An empty
+// idPrefix deletes all the sessions of the store. User index entries of
+// purged sessions are pruned lazily, by ListUserSessions and by the next
+// save of a session bound to the same user, so that they don't count
+// toward WithMaxSessionsPerUser.
+func (s *RediStore) Purge(ctx context.Context, idPrefix string) (int, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return 0, err
+	}
+	pattern := matchPattern(s.keyPrefix + idPrefix)
+	var (
+		cursor uint64
+		total  int
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", s.scanCount))
+		if err != nil {
+			return total, err
+		}
+		var keys []string
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return total, err
+		}
+		if len(keys) > 0 {
+			n, err := s.deleteKeys(conn, keys)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+		if cursor == 0 {
+			return total, nil
+		}
+	}
+}
+
+// deleteKeys deletes the given session keys and their metadata, and
+// returns the number of sessions deleted.
+func (s *RediStore) deleteKeys(conn redis.Conn, keys []string) (int, error) {
+	args := make([]interface{}, len(keys))
+	meta := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+		meta[i] = s.metadataKey(key[len(s.keyPrefix):])
+	}
+	if err := conn.Send("DEL", args...); err != nil {
+		return 0, err
+	}
+	if err := conn.Send("DEL", meta...); err != nil {
+		return 0, err
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+	n, err := redis.Int(conn.Receive())
+	if err != nil {
+		return 0, err
+	}
+	if _, err := conn.Receive(); err != nil {
+		return n, err
+	}
+	for _, key := range keys {
+		s.cache.invalidate(key)
+	}
+	return n, s.publishInvalidation(conn, keys...)
+}
+
+// internalValuePrefix starts the session.Values keys the store reserves
+// for itself.
+const internalValuePrefix = "_redistore_"
+
+// IsInternalValue reports whether key is a session.Values key reserved by
+// the store, such as the CSRF secret, the client fingerprint or the bound
+// user. The admin handler and the redistore command hide these values
+// from operators.
+func IsInternalValue(key interface{}) bool {
+	k, ok := key.(string)
+	return ok && strings.HasPrefix(k, internalValuePrefix)
+}
+
+// AdminOption configures the handler returned by NewAdminHandler.
+type AdminOption func(*adminHandler)
+
+// WithAdminAuthorizer sets the function deciding whether a request may use
+// the admin handler. A non-nil error denies the request with 403
+// Forbidden. Without an authorizer every request is denied.
+func WithAdminAuthorizer(fn func(r *http.Request) error) AdminOption {
+	return func(h *adminHandler) {
+		h.authorize = fn
+	}
+}
+
+// WithAdminRedactor sets a function applied to every session value before
+// it is returned by the admin handler, so that secrets such as tokens are
+// never shown to operators. It receives the key and value, and returns the
+// value to show.
+func WithAdminRedactor(fn func(key string, value interface{}) interface{}) AdminOption {
+	return func(h *adminHandler) {
+		h.redact = fn
+	}
+}
+
+// RedactKeys returns a redactor for WithAdminRedactor that masks the values
+// of the given session keys.
+func RedactKeys(keys ...string) func(key string, value interface{}) interface{} {
+	redacted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		redacted[k] = true
+	}
+	return func(key string, value interface{}) interface{} {
+		if redacted[key] {
+			return "[REDACTED]"
+		}
+		return value
+	}
+}
+
+// adminHandler serves the JSON endpoints of NewAdminHandler.
+type adminHandler struct {
+	store     *RediStore
+	authorize func(r *http.Request) error
+	redact    func(key string, value interface{}) interface{}
+	mux       *http.ServeMux
+}
+
+// NewAdminHandler returns an http.Handler exposing JSON endpoints to
+// inspect and revoke the sessions of store:
+//
+//	GET    /sessions?cursor=0&count=100  list a page of session IDs and TTLs
+//	GET    /sessions/{id}                show a session's values and TTL
+//	DELETE /sessions/{id}                delete a session
+//	DELETE /sessions?prefix=abc          delete sessions whose ID has a prefix
+//
+// Requests are denied unless WithAdminAuthorizer allows them. Values the
+// store keeps for itself, see IsInternalValue, are never shown. Mount the
+// handler under a path of your choice with http.StripPrefix.
+//
+// Example:
+//
+//	admin := redistore.NewAdminHandler(store,
+//	    redistore.WithAdminAuthorizer(requireOperator),
+//	    redistore.WithAdminRedactor(redistore.RedactKeys("token")),
+//	)
+//	http.Handle("/admin/", http.StripPrefix("/admin", admin))
+func NewAdminHandler(store *RediStore, opts ...AdminOption) http.Handler {
+	h := &adminHandler{store: store}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("GET /sessions", h.list)
+	h.mux.HandleFunc("DELETE /sessions", h.purge)
+	h.mux.HandleFunc("GET /sessions/{id}", h.show)
+	h.mux.HandleFunc("DELETE /sessions/{id}", h.delete)
+	return h
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize == nil {
+		writeJSONError(w, http.StatusForbidden, errors.New("no authorizer configured"))
+		return
+	}
+	if err := h.authorize(r); err != nil {
+		writeJSONError(w, http.StatusForbidden, err)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// adminSession is a session as listed by the admin handler.
+type adminSession struct {
+	ID     string                 `json:"id"`
+	TTL    int64                  `json:"ttl"`
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+func (h *adminHandler) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var cursor uint64
+	if v := q.Get("cursor"); v != "" {
+		c, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid cursor: %w", err))
+			return
+		}
+		cursor = c
+	}
+	count := h.store.scanCount
+	if v := q.Get("count"); v != "" {
+		c, err := strconv.Atoi(v)
+		if err != nil || c <= 0 {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid count %q", v))
+			return
+		}
+		count = c
+	}
+
+	next, list, err := h.store.listPage(cursor, count)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": list,
+		// A zero cursor means the walk is complete.
+		"cursor": strconv.FormatUint(next, 10),
+	})
+}
+
+// listPage returns one SCAN page of session IDs with their TTL in seconds.
+func (s *RediStore) listPage(cursor uint64, count int) (uint64, []adminSession, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	next, keys, err := s.scanPage(conn, cursor, count)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, key := range keys {
+		if err := conn.Send("TTL", key); err != nil {
+			return 0, nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, nil, err
+	}
+	list := make([]adminSession, 0, len(keys))
+	for _, key := range keys {
+		ttl, err := redis.Int64(conn.Receive())
+		if err != nil {
+			return 0, nil, err
+		}
+		if ttl == -2 {
+			continue // expired since SCAN
+		}
+		list = append(list, adminSession{ID: key[len(s.keyPrefix):], TTL: ttl})
+	}
+	return next, list, nil
+}
+
+func (h *adminHandler) show(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	session := h.store.newStoredSession(id)
+	ok, err := h.store.load(r.Context(), session)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, ErrSessionNotFound)
+		return
+	}
+	ttl, err := h.store.TTL(id)
+	if err != nil {
+		writeJSONError(w, statusFor(err), err)
+		return
+	}
+	values := make(map[string]interface{}, len(session.Values))
+	for k, v := range session.Values {
+		if IsInternalValue(k) {
+			continue
+		}
+		key := fmt.Sprint(k)
+		if h.redact != nil {
+			v = h.redact(key, v)
+		}
+		// Values that JSON can't represent, such as gob-only types, are
+		// shown in their Go syntax.
+		if _, err := json.Marshal(v); err != nil {
+			v = fmt.Sprintf("%#v", v)
+		}
+		values[key] = v
+	}
+	writeJSON(w, http.StatusOK, adminSession{
+		ID:     id,
+		TTL:    int64(ttl / time.Second),
+		Values: values,
+	})
+}
+
+func (h *adminHandler) delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.DeleteByID(r.PathValue("id")); err != nil {
+		writeJSONError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *adminHandler) purge(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		writeJSONError(w, http.StatusBadRequest, errors.New("prefix is required"))
+		return
+	}
+	n, err := h.store.Purge(r.Context(), prefix)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"deleted": n})
+}
+
+// statusFor maps store errors to HTTP status codes.
+func statusFor(err error) int {
+	if errors.Is(err, ErrSessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("redistore: error writing admin response: %v\n", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,142 @@
+package redistore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestAdminHandler(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("admin_"+newSessionID()+"_"),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	ids := []string{"aaa1", "aaa2", "bbb1"}
+	for _, id := range ids {
+		session := sessions.NewSession(store, "admin")
+		session.Options = &sessions.Options{MaxAge: 60}
+		session.ID = id
+		session.Values["user"] = "alice"
+		session.Values["token"] = "secret"
+		session.Values[csrfValueKey] = "csrf-secret"
+		if err := store.save(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := NewAdminHandler(store,
+		WithAdminAuthorizer(func(r *http.Request) error {
+			if r.Header.Get("X-Operator") == "" {
+				return errors.New("operators only")
+			}
+			return nil
+		}),
+		WithAdminRedactor(RedactKeys("token")),
+	)
+	do := func(method, target string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if authorized {
+			req.Header.Set("X-Operator", "ops")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("GET", "/sessions", false); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without authorization, got %d", rec.Code)
+	}
+
+	// Page through the sessions.
+	seen := make(map[string]bool)
+	cursor := "0"
+	for {
+		rec := do("GET", "/sessions?count=1&cursor="+cursor, true)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("List failed: %d %s", rec.Code, rec.Body)
+		}
+		var page struct {
+			Sessions []adminSession `json:"sessions"`
+			Cursor   string         `json:"cursor"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range page.Sessions {
+			seen[s.ID] = true
+			if s.TTL <= 0 {
+				t.Errorf("Expected positive TTL for %s, got %d", s.ID, s.TTL)
+			}
+		}
+		if cursor = page.Cursor; cursor == "0" {
+			break
+		}
+	}
+	if len(seen) != len(ids) {
+		t.Errorf("Expected %d sessions, got %v", len(ids), seen)
+	}
+
+	rec := do("GET", "/sessions/aaa1", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Show failed: %d %s", rec.Code, rec.Body)
+	}
+	var shown adminSession
+	if err := json.Unmarshal(rec.Body.Bytes(), &shown); err != nil {
+		t.Fatal(err)
+	}
+	if len(shown.Values) != 2 || shown.Values["user"] != "alice" || shown.Values["token"] != "[REDACTED]" {
+		t.Errorf("Unexpected values: %v", shown.Values)
+	}
+	if rec := do("GET", "/sessions/missing", true); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+
+	if rec := do("DELETE", "/sessions/bbb1", true); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if rec := do("DELETE", "/sessions", true); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without prefix, got %d", rec.Code)
+	}
+	rec = do("DELETE", "/sessions?prefix=aaa", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Purge failed: %d %s", rec.Code, rec.Body)
+	}
+	var purged map[string]int
+	if err := json.Unmarshal(rec.Body.Bytes(), &purged); err != nil {
+		t.Fatal(err)
+	}
+	if purged["deleted"] != 2 {
+		t.Errorf("Expected 2 deleted sessions, got %v", purged)
+	}
+	for _, id := range ids {
+		if _, err := store.TTL(id); err != ErrSessionNotFound {
+			t.Errorf("Expected %s to be gone, got %v", id, err)
+		}
+	}
+}
+
+func TestAdminHandler_DeniesWithoutAuthorizer(t *testing.T) {
+	handler := NewAdminHandler(&RediStore{})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/sessions", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+}
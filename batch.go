@@ -0,0 +1,206 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+// BatchResult reports the outcome of a single item in a LoadMany or
+// SaveMany call. Results are returned in the same order as the input.
+//
+// Fields:
+//
+//	Session: The loaded or saved session.
+//	Found: Whether data for the session existed in Redis (LoadMany only).
+//	Err: The error for this item, or nil on success.
+type BatchResult struct {
+	Session *sessions.Session
+	Found   bool
+	Err     error
+}
+
+// LoadMany reads the sessions with the given IDs using a single MGET.
+//
+// Each session is created with the store's default options and an empty
+// name, since the cookie name is not known outside of a request. Missing
+// sessions are reported with Found == false and an empty Values map;
+// deserialization failures are reported per item in BatchResult.Err.
+//
+// The returned error is non-nil only when the round trip itself fails.
+func (s *RediStore) LoadMany(ids []string) ([]BatchResult, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = s.sessionKey(id)
+	}
+	values, err := redis.Values(conn.Do("MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(ids))
+	for i, id := range ids {
+		session := s.newStoredSession(id)
+		session.IsNew = true
+		results[i].Session = session
+		if values[i] == nil {
+			continue
+		}
+		b, err := redis.Bytes(values[i], nil)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if err := s.serializer.Deserialize(b, session); err != nil {
+			results[i].Err = err
+			continue
+		}
+		restoreMaxAge(session)
+		results[i].Found = true
+		session.IsNew = false
+	}
+	return results, nil
+}
+
+// SaveMany writes the given sessions using a single pipelined round trip.
+//
+// Sessions with Options.MaxAge < 0 are deleted with their metadata, all
+// others are prepared and stored with SETEX using the same rules as Save,
+// and sessions bound to a user with BindUser are kept in the user's index.
+// Sessions without an ID are given a new one. Since there is no request,
+// the client fingerprint of WithClientBinding is not recorded; sessions
+// without one are bound on their next Save. Each session is serialized
+// with its serializer and checked against maxLength; a session that fails
+// either step is reported in its BatchResult and is not sent to Redis,
+// while the rest of the batch is still written. Every item is reported to
+// the observer, if any.
+//
+// The returned error is non-nil only when the round trip itself fails.
+func (s *RediStore) SaveMany(batch []*sessions.Session) ([]BatchResult, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	start := time.Now()
+	results := make([]BatchResult, len(batch))
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+	// pending tracks the commands sent; data is nil for deletions, and
+	// extra counts replies to skip after the first one.
+	type pending struct {
+		i     int
+		key   string
+		uid   string
+		data  []byte
+		ttl   time.Duration
+		extra int
+	}
+	sent := make([]pending, 0, len(batch))
+	for i, session := range batch {
+		results[i].Session = session
+		if session.Options != nil && session.Options.MaxAge < 0 {
+			n, err := s.sendDelete(conn, session)
+			if err != nil {
+				return nil, err
+			}
+			sent = append(sent, pending{i: i, key: s.namedSessionKey(session.Name(), session.ID), extra: n - 1})
+			continue
+		}
+		if session.Options == nil {
+			options := *s.profile(session.Name()).options
+			session.Options = &options
+		}
+		if err := s.prepareSave(nil, session); err != nil {
+			results[i].Err = err
+			s.observe(OpSave, session.Name(), start, 0, OutcomeWrite, err)
+			continue
+		}
+		b, err := s.encode(session)
+		if err != nil {
+			results[i].Err = err
+			s.observe(OpSave, session.Name(), start, 0, OutcomeWrite, err)
+			continue
+		}
+		key := s.namedSessionKey(session.Name(), session.ID)
+		p := pending{i: i, key: key, data: b, ttl: time.Duration(s.ttl(session)) * time.Second}
+		if p.uid = UserID(session); p.uid != "" {
+			err = saveBoundScript.Send(conn, s.boundSaveArgs(p.uid, key, session.ID, s.ttl(session), b)...)
+		} else {
+			err = conn.Send("SETEX", key, s.ttl(session), b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, p)
+	}
+	if len(sent) == 0 {
+		return results, nil
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	changed := make([]string, 0, len(sent))
+	for _, p := range sent {
+		reply, err := conn.Receive()
+		for ; p.extra > 0; p.extra-- {
+			if _, xerr := conn.Receive(); err == nil {
+				err = xerr
+			}
+		}
+		if err != nil {
+			// Redis errors belong to a single command; anything else
+			// means the connection is broken and the batch is lost.
+			if _, ok := err.(redis.Error); !ok {
+				return nil, err
+			}
+		} else if p.uid != "" && p.data != nil {
+			var evicted []string
+			if evicted, err = s.boundSaveResult(p.uid, reply); err == nil {
+				changed = append(changed, evicted...)
+			}
+		}
+		name := results[p.i].Session.Name()
+		if p.data == nil {
+			s.observe(OpDelete, name, start, 0, OutcomeWrite, err)
+		} else {
+			s.observe(OpSave, name, start, len(p.data), OutcomeWrite, err)
+		}
+		if err != nil {
+			results[p.i].Err = err
+			continue
+		}
+		if p.data == nil {
+			s.cache.invalidate(p.key)
+		} else {
+			s.cache.set(p.key, p.data, p.ttl)
+		}
+		changed = append(changed, p.key)
+	}
+	if err := s.publishInvalidation(conn, changed...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,157 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+func TestSaveManyLoadMany(t *testing.T) {
+	addr := setup()
+	store := createTestStore(t, addr)
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	batch := make([]*sessions.Session, 3)
+	for i := range batch {
+		session := sessions.NewSession(store, "batch")
+		session.Values["n"] = i
+		batch[i] = session
+	}
+	// The last session is too big and must fail without aborting the batch.
+	batch[2].Values["big"] = make([]byte, 8192)
+
+	saved, err := store.SaveMany(batch)
+	if err != nil {
+		t.Fatalf("SaveMany failed: %v", err)
+	}
+	if len(saved) != len(batch) {
+		t.Fatalf("Expected %d results, got %d", len(batch), len(saved))
+	}
+	for i := 0; i < 2; i++ {
+		if saved[i].Err != nil {
+			t.Errorf("Expected session %d to be saved, got %v", i, saved[i].Err)
+		}
+		if saved[i].Session.ID == "" {
+			t.Errorf("Expected session %d to be given an ID", i)
+		}
+	}
+	if saved[2].Err == nil {
+		t.Error("Expected oversized session to fail")
+	}
+
+	ids := []string{batch[0].ID, "missing", batch[1].ID}
+	loaded, err := store.LoadMany(ids)
+	if err != nil {
+		t.Fatalf("LoadMany failed: %v", err)
+	}
+	if len(loaded) != len(ids) {
+		t.Fatalf("Expected %d results, got %d", len(ids), len(loaded))
+	}
+	if !loaded[0].Found || loaded[0].Session.Values["n"] != 0 {
+		t.Errorf("Unexpected first result: %+v", loaded[0])
+	}
+	if loaded[1].Found || loaded[1].Err != nil || !loaded[1].Session.IsNew {
+		t.Errorf("Expected missing session to be reported as not found: %+v", loaded[1])
+	}
+	if !loaded[2].Found || loaded[2].Session.Values["n"] != 1 {
+		t.Errorf("Unexpected third result: %+v", loaded[2])
+	}
+
+	// Deleting through SaveMany mirrors Save with MaxAge < 0.
+	batch[0].Options.MaxAge = -1
+	if _, err := store.SaveMany(batch[:1]); err != nil {
+		t.Fatalf("SaveMany delete failed: %v", err)
+	}
+	loaded, err = store.LoadMany(ids[:1])
+	if err != nil {
+		t.Fatalf("LoadMany failed: %v", err)
+	}
+	if loaded[0].Found {
+		t.Error("Expected deleted session to be gone")
+	}
+}
+
+func TestSaveMany_SharedSteps(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithMetadata(),
+		WithClientBinding(ClientBinding{UserAgent: true, Action: BindingFlag}),
+		WithObserver(ObserverFunc(func(e Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		})),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	session, err := store.New(req, "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	session.Values[bindingMismatchValueKey] = true
+	session.Options.MaxAge = 60
+	events = nil
+	if _, err := store.SaveMany([]*sessions.Session{session}); err != nil {
+		t.Fatalf("SaveMany failed: %v", err)
+	}
+	loaded, err := store.LoadMany([]string{session.ID})
+	if err != nil {
+		t.Fatalf("LoadMany failed: %v", err)
+	}
+	values := loaded[0].Session.Values
+	if _, ok := values[bindingMismatchValueKey]; ok {
+		t.Error("Expected the binding mismatch mark not to be saved")
+	}
+	if values[fingerprintValueKey] == nil || values[maxAgeValueKey] != 60 {
+		t.Errorf("Expected the fingerprint and MaxAge to be saved, got %v", values)
+	}
+	if len(events) != 1 || events[0].Op != OpSave || events[0].Bytes == 0 || events[0].Err != nil {
+		t.Errorf("Expected a save event, got %+v", events)
+	}
+
+	// Deleting drops the metadata record too.
+	session.Options.MaxAge = -1
+	events = nil
+	if _, err := store.SaveMany([]*sessions.Session{session}); err != nil {
+		t.Fatalf("SaveMany delete failed: %v", err)
+	}
+	conn := store.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if n, err := redis.Int(conn.Do("EXISTS", store.metadataKey(session.ID))); err != nil || n != 0 {
+		t.Errorf("Expected the metadata to be deleted, got %d (%v)", n, err)
+	}
+	if len(events) != 1 || events[0].Op != OpDelete || events[0].Err != nil {
+		t.Errorf("Expected a delete event, got %+v", events)
+	}
+}
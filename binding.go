@@ -0,0 +1,237 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// Session.Values keys used by client binding, in the manner of
+// userValueKey. The mismatch marker only lives in memory and is never
+// saved.
+const (
+	fingerprintValueKey     = "_redistore_fp"
+	bindingMismatchValueKey = "_redistore_fp_mismatch"
+)
+
+// Fingerprint fields reported to ClientBinding.OnMismatch.
+const (
+	FieldUserAgent  = "user-agent"
+	FieldIP         = "ip"
+	FieldClientCert = "client-cert"
+)
+
+// BindingAction decides what happens when a session is loaded by a client
+// whose fingerprint differs from the one recorded when it was saved.
+type BindingAction int
+
+const (
+	// BindingReject refuses the session: New returns ErrBindingMismatch
+	// and an empty new session. The stored session is left untouched for
+	// its legitimate owner.
+	BindingReject BindingAction = iota
+
+	// BindingFlag loads the session and marks it, see BindingMismatch. The
+	// recorded fingerprint is kept.
+	BindingFlag
+
+	// BindingRotate loads and marks the session, and moves it to a new ID
+	// bound to the current client on the next Save, as RegenerateID does.
+	BindingRotate
+)
+
+// ErrBindingMismatch is returned by New when the client's fingerprint
+// doesn't match the session's and BindingReject is in effect.
+var ErrBindingMismatch = errors.New("redistore: session bound to another client")
+
+// ClientBinding configures the fingerprint recorded with each session and
+// checked when it is loaded. Only the enabled fields are compared, and
+// sessions saved without a fingerprint are bound on their next Save.
+type ClientBinding struct {
+	// UserAgent binds the session to a hash of the User-Agent header.
+	UserAgent bool
+
+	// IPv4Bits and IPv6Bits bind the session to the prefix of the client
+	// address of that length, e.g. 24 for an IPv4 /24 network. Zero
+	// leaves addresses of that family unbound.
+	IPv4Bits int
+	IPv6Bits int
+
+	// ClientCert binds the session to a hash of the TLS client
+	// certificate, if any.
+	ClientCert bool
+
+	// ClientIP returns the address of the client. Default is the host of
+	// r.RemoteAddr; set it when running behind a trusted proxy.
+	ClientIP func(r *http.Request) string
+
+	// Action decides what happens on mismatch. Default is BindingReject.
+	Action BindingAction
+
+	// OnMismatch, if set, is called with the mismatched fields, such as
+	// FieldIP, whenever a session fails the check, for example to log a
+	// suspected hijack. It is called before Action is applied.
+	OnMismatch func(r *http.Request, session *sessions.Session, fields []string)
+}
+
+// WithClientBinding records a fingerprint of the client with each session
+// and checks it whenever the session is loaded.
+//
+// Example:
+//
+//	WithClientBinding(ClientBinding{
+//	    UserAgent:  true,
+//	    IPv4Bits:   24,
+//	    IPv6Bits:   64,
+//	    Action:     BindingRotate,
+//	    OnMismatch: logSuspectedHijack,
+//	})
+func WithClientBinding(binding ClientBinding) Option {
+	return func(cfg *storeConfig) error {
+		if !binding.UserAgent && !binding.ClientCert && binding.IPv4Bits == 0 && binding.IPv6Bits == 0 {
+			return errors.New("client binding must enable at least one field")
+		}
+		if binding.IPv4Bits < 0 || binding.IPv4Bits > 32 {
+			return fmt.Errorf("IPv4 prefix must be between 0 and 32 bits, got %d", binding.IPv4Bits)
+		}
+		if binding.IPv6Bits < 0 || binding.IPv6Bits > 128 {
+			return fmt.Errorf("IPv6 prefix must be between 0 and 128 bits, got %d", binding.IPv6Bits)
+		}
+		if binding.Action < BindingReject || binding.Action > BindingRotate {
+			return fmt.Errorf("unknown binding action %d", binding.Action)
+		}
+		if binding.ClientIP == nil {
+			binding.ClientIP = remoteHost
+		}
+		cfg.binding = &binding
+		return nil
+	}
+}
+
+// BindingMismatch reports whether the session was loaded by a client
+// whose fingerprint doesn't match the session's, under BindingFlag or
+// BindingRotate. The mark is cleared when the session is saved.
+func BindingMismatch(session *sessions.Session) bool {
+	mismatch, _ := session.Values[bindingMismatchValueKey].(bool)
+	return mismatch
+}
+
+// fingerprint returns the fingerprint of the client of r as
+// "field=hash" pairs separated by semicolons, for the enabled fields.
+func (b *ClientBinding) fingerprint(r *http.Request) string {
+	var parts []string
+	add := func(field, value string) {
+		sum := sha256.Sum256([]byte(value))
+		parts = append(parts, field+"="+hex.EncodeToString(sum[:12]))
+	}
+	if b.UserAgent {
+		add(FieldUserAgent, r.UserAgent())
+	}
+	if b.IPv4Bits > 0 || b.IPv6Bits > 0 {
+		add(FieldIP, b.ipPrefix(b.ClientIP(r)))
+	}
+	if b.ClientCert {
+		cert := ""
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			cert = string(r.TLS.PeerCertificates[0].Raw)
+		}
+		add(FieldClientCert, cert)
+	}
+	return strings.Join(parts, ";")
+}
+
+// ipPrefix returns the bound network of the address ip, or ip itself if it
+// doesn't parse.
+func (b *ClientBinding) ipPrefix(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	bits := b.IPv6Bits
+	if addr.Is4() {
+		bits = b.IPv4Bits
+	}
+	if bits == 0 {
+		return "" // family not bound
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// mismatchedFields returns the fields of the recorded fingerprint that
+// differ from the current one.
+func mismatchedFields(recorded, current string) []string {
+	fields := make(map[string]string)
+	for _, part := range strings.Split(recorded, ";") {
+		if field, hash, ok := strings.Cut(part, "="); ok {
+			fields[field] = hash
+		}
+	}
+	var mismatched []string
+	for _, part := range strings.Split(current, ";") {
+		field, hash, _ := strings.Cut(part, "=")
+		// Fields enabled since the session was saved can't be compared.
+		if prev, ok := fields[field]; ok && prev != hash {
+			mismatched = append(mismatched, field)
+		}
+	}
+	return mismatched
+}
+
+// checkBinding compares the fingerprint of a loaded session with the
+// client of r and applies the configured action. Rejected sessions are
+// reset to empty new sessions.
+func (s *RediStore) checkBinding(r *http.Request, session *sessions.Session) error {
+	if s.binding == nil {
+		return nil
+	}
+	recorded, _ := session.Values[fingerprintValueKey].(string)
+	if recorded == "" {
+		return nil
+	}
+	fields := mismatchedFields(recorded, s.binding.fingerprint(r))
+	if len(fields) == 0 {
+		return nil
+	}
+	if s.binding.OnMismatch != nil {
+		s.binding.OnMismatch(r, session, fields)
+	}
+	if s.binding.Action == BindingReject {
+		session.ID = ""
+		session.Values = make(map[interface{}]interface{})
+		session.IsNew = true
+		return ErrBindingMismatch
+	}
+	session.Values[bindingMismatchValueKey] = true
+	return nil
+}
+
+// recordBinding prepares a session for saving: it clears the mismatch
+// mark and records the fingerprint of the client of r if the session has
+// none, or always if refresh is set. A nil r only clears the mark.
+func (s *RediStore) recordBinding(r *http.Request, session *sessions.Session, refresh bool) {
+	if s.binding == nil {
+		return
+	}
+	delete(session.Values, bindingMismatchValueKey)
+	if r == nil {
+		return
+	}
+	if _, ok := session.Values[fingerprintValueKey]; refresh || !ok {
+		session.Values[fingerprintValueKey] = s.binding.fingerprint(r)
+	}
+}
@@ -0,0 +1,144 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestClientBinding(t *testing.T) {
+	addr := setup()
+	var hijacks [][]string
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithClientBinding(ClientBinding{
+			UserAgent: true,
+			IPv4Bits:  24,
+			OnMismatch: func(r *http.Request, session *sessions.Session, fields []string) {
+				hijacks = append(hijacks, fields)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	request := func(ua, ip, cookie string) *http.Request {
+		req, _ := http.NewRequestWithContext(
+			context.Background(), "GET", "http://localhost:8080/", nil)
+		req.Header.Set("User-Agent", ua)
+		req.RemoteAddr = ip + ":1234"
+		if cookie != "" {
+			req.Header.Add("Cookie", cookie)
+		}
+		return req
+	}
+
+	req := request("browser", "10.0.0.1", "")
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	cookie := getCookies(t, rsp)[0]
+
+	// The same client within the bound network.
+	if s, err := store.New(request("browser", "10.0.0.99", cookie), "session-key"); err != nil || s.IsNew {
+		t.Fatalf("Expected the session to load, got %v", err)
+	}
+
+	// Another client is rejected.
+	s, err := store.New(request("curl", "10.0.1.1", cookie), "session-key")
+	if err != ErrBindingMismatch {
+		t.Fatalf("Expected ErrBindingMismatch, got %v", err)
+	}
+	if !s.IsNew || s.ID != "" || len(s.Values) != 0 {
+		t.Errorf("Expected an empty new session, got %q %v", s.ID, s.Values)
+	}
+	if len(hijacks) != 1 || !slices.Equal(hijacks[0], []string{FieldUserAgent, FieldIP}) {
+		t.Errorf("Expected the hook to report both fields, got %v", hijacks)
+	}
+
+	// Flagged sessions load and keep their fingerprint.
+	store.binding.Action = BindingFlag
+	req = request("curl", "10.0.0.1", cookie)
+	s, err = store.New(req, "session-key")
+	if err != nil || s.Values["name"] != "alice" || !BindingMismatch(s) {
+		t.Fatalf("Expected a flagged session, got %v %v", err, s.Values)
+	}
+	if err := s.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if BindingMismatch(s) {
+		t.Error("Expected the mark to be cleared by Save")
+	}
+	if _, err := store.New(request("browser", "10.0.0.1", cookie), "session-key"); err != nil {
+		t.Errorf("Expected the original client to keep the session, got %v", err)
+	}
+
+	// Rotated sessions move to a new ID bound to the new client.
+	store.binding.Action = BindingRotate
+	req = request("curl", "10.0.0.1", cookie)
+	s, err = store.New(req, "session-key")
+	if err != nil || !BindingMismatch(s) {
+		t.Fatalf("Expected a flagged session, got %v", err)
+	}
+	oldID := s.ID
+	rsp = NewRecorder()
+	if err := s.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID == oldID {
+		t.Error("Expected the session to move to a new ID")
+	}
+	s, err = store.New(request("curl", "10.0.0.1", getCookies(t, rsp)[0]), "session-key")
+	if err != nil || BindingMismatch(s) || s.Values["name"] != "alice" {
+		t.Errorf("Expected the new client to be bound, got %v %v", err, s.Values)
+	}
+}
+
+func TestClientBinding_Fingerprint(t *testing.T) {
+	b := &ClientBinding{IPv4Bits: 16, IPv6Bits: 48}
+	tests := map[string]string{
+		"192.168.10.20":  "192.168.0.0/16",
+		"::ffff:1.2.3.4": "1.2.0.0/16",
+		"2001:db8:1:2::": "2001:db8:1::/48",
+		"not-an-ip":      "not-an-ip",
+	}
+	for ip, want := range tests {
+		if got := b.ipPrefix(ip); got != want {
+			t.Errorf("ipPrefix(%q) = %q, want %q", ip, got, want)
+		}
+	}
+	if f := mismatchedFields("user-agent=a;ip=b", "user-agent=a;ip=c;client-cert=d"); !slices.Equal(f, []string{"ip"}) {
+		t.Errorf("Expected only ip to mismatch, got %v", f)
+	}
+}
+
+func TestWithClientBinding_Invalid(t *testing.T) {
+	cfg := defaultConfig()
+	for _, b := range []ClientBinding{
+		{},
+		{IPv4Bits: 33},
+		{IPv6Bits: -1},
+		{UserAgent: true, Action: BindingAction(42)},
+	} {
+		if err := WithClientBinding(b)(cfg); err == nil {
+			t.Errorf("Expected error for %+v", b)
+		}
+	}
+}
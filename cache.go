@@ -0,0 +1,512 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// InvalidationMode selects how a local session cache learns about writes
+// made by other processes sharing the same Redis.
+type InvalidationMode int
+
+const (
+	// InvalidatePubSub publishes every save and delete on an invalidation
+	// channel. Every store with a local cache subscribes to that channel
+	// and evicts the affected entry. This works with any Redis version.
+	InvalidatePubSub InvalidationMode = iota
+
+	// InvalidateTracking uses Redis server-assisted client-side caching
+	// (CLIENT TRACKING in broadcast mode, Redis 6 or later). The server
+	// reports every modified key under the store's key prefix, including
+	// expirations and writes made by clients that don't use this package.
+	// Since redigo speaks RESP2, notifications are redirected to a
+	// dedicated connection subscribed to __redis__:invalidate.
+	InvalidateTracking
+)
+
+// trackingChannel is the channel Redis uses to deliver CLIENT TRACKING
+// notifications to RESP2 connections.
+const trackingChannel = "__redis__:invalidate"
+
+// trackingCheckInterval is how often the connection that enabled CLIENT
+// TRACKING is pinged. Redis stops sending notifications when it closes,
+// without telling the subscribed connection.
+var trackingCheckInterval = 5 * time.Second
+
+// WithLocalCache enables an in-process LRU cache of serialized sessions in
+// front of Redis. At most size entries are kept, each for at most ttl and
+// never past the expiry of its Redis key. Entries are evicted on every
+// node when a session is saved or deleted, see WithCacheInvalidation.
+//
+// Example:
+//
+//	WithLocalCache(10000, 30*time.Second)
+func WithLocalCache(size int, ttl time.Duration) Option {
+	return func(cfg *storeConfig) error {
+		if size <= 0 {
+			return fmt.Errorf("cache size must be positive, got %d", size)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("cache ttl must be positive, got %v", ttl)
+		}
+		cfg.cacheSize = size
+		cfg.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithCacheInvalidation sets how the local cache is kept consistent across
+// processes. Default is InvalidatePubSub. Only applies with WithLocalCache.
+func WithCacheInvalidation(mode InvalidationMode) Option {
+	return func(cfg *storeConfig) error {
+		if mode != InvalidatePubSub && mode != InvalidateTracking {
+			return fmt.Errorf("unknown invalidation mode %d", mode)
+		}
+		cfg.invalidation = mode
+		return nil
+	}
+}
+
+// WithInvalidationChannel sets the pub/sub channel used by InvalidatePubSub.
+// Default is "redistore:invalidate:" followed by the key prefix. All stores
+// sharing sessions must use the same channel.
+func WithInvalidationChannel(channel string) Option {
+	return func(cfg *storeConfig) error {
+		if channel == "" {
+			return fmt.Errorf("invalidation channel cannot be empty")
+		}
+		cfg.invalidationChannel = channel
+		return nil
+	}
+}
+
+// localCache is a size and age bounded LRU of serialized session payloads
+// keyed by Redis key. A nil *localCache is a valid, always empty cache.
+type localCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+	// filling counts the loads from Redis in flight per key, and dirty
+	// records keys invalidated meanwhile, so that a load racing with a
+	// remote write does not cache the stale value.
+	filling map[string]int
+	dirty   map[string]bool
+}
+
+type cacheEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+func newLocalCache(size int, ttl time.Duration) *localCache {
+	return &localCache{
+		size:    size,
+		ttl:     ttl,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		filling: make(map[string]int),
+		dirty:   make(map[string]bool),
+	}
+}
+
+// get returns the cached payload for key if present and not expired.
+func (c *localCache) get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.data, true
+}
+
+// set stores data for key, evicting the least recently used entry if full.
+// The entry expires after the cache ttl or after ttl, whichever is sooner;
+// a ttl <= 0 means the Redis key doesn't expire. A load of key in flight
+// may have read the previous value, so it is marked dirty to keep endFill
+// from overwriting data.
+func (c *localCache) set(key string, data []byte, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.filling[key] > 0 {
+		c.dirty[key] = true
+	}
+	c.setLocked(key, data, ttl)
+}
+
+// beginFill must be called before reading key from Redis. Every call must
+// be paired with a call to endFill.
+func (c *localCache) beginFill(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filling[key]++
+}
+
+// endFill caches data read from Redis for key with the remaining ttl of
+// the Redis key, unless key was invalidated since beginFill. A nil data
+// only ends the fill.
+func (c *localCache) endFill(key string, data []byte, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data != nil && !c.dirty[key] {
+		c.setLocked(key, data, ttl)
+	}
+	if c.filling[key]--; c.filling[key] <= 0 {
+		delete(c.filling, key)
+		delete(c.dirty, key)
+	}
+}
+
+func (c *localCache) setLocked(key string, data []byte, ttl time.Duration) {
+	if ttl <= 0 || ttl > c.ttl {
+		ttl = c.ttl
+	}
+	expires := time.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.data = data
+		e.expires = expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, data: data, expires: expires})
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// invalidate drops key from the cache.
+func (c *localCache) invalidate(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.filling[key] > 0 {
+		c.dirty[key] = true
+	}
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// purge drops every entry from the cache.
+func (c *localCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.filling {
+		c.dirty[key] = true
+	}
+	c.ll.Init()
+	clear(c.items)
+}
+
+func (c *localCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// invalidator keeps a local cache consistent by listening for
+// invalidation messages on a dedicated Redis connection.
+type invalidator struct {
+	mode    InvalidationMode
+	channel string
+	// origin identifies this store in published messages so that it
+	// doesn't evict entries it has just written itself.
+	origin string
+
+	mu     sync.Mutex
+	conn   redis.Conn // subscribed connection, nil while reconnecting
+	closed atomic.Bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newInvalidator(mode InvalidationMode, channel string) *invalidator {
+	return &invalidator{
+		mode:    mode,
+		channel: channel,
+		origin:  newSessionID(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// startInvalidation starts listening for invalidation messages. It returns
+// once the subscription is active, so that no write made after NewStore
+// returns can be missed.
+func (s *RediStore) startInvalidation() error {
+	inv := s.invalidator
+	ready := make(chan error, 1)
+	go func() {
+		defer close(inv.done)
+		started := false
+		backoff := 100 * time.Millisecond
+		for {
+			err := s.listenInvalidations(func() {
+				if !started {
+					started = true
+					ready <- nil
+				}
+			})
+			// Messages may have been lost while disconnected.
+			s.cache.purge()
+			if !started {
+				if err == nil {
+					err = errors.New("invalidation listener stopped before subscribing")
+				}
+				ready <- err
+				return
+			}
+			if inv.closed.Load() {
+				return
+			}
+			fmt.Printf("redistore: invalidation listener error: %v\n", err)
+			select {
+			case <-inv.stop:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 5*time.Second {
+				backoff *= 2
+			}
+		}
+	}()
+	return <-ready
+}
+
+// listenInvalidations subscribes to the invalidation channel and applies
+// messages until the connection fails or the store is closed. onReady is
+// called whenever the subscription is confirmed. With InvalidateTracking,
+// it also returns when the tracking connection fails, so that tracking is
+// enabled again on a new connection.
+func (s *RediStore) listenInvalidations(onReady func()) error {
+	inv := s.invalidator
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	channel := inv.channel
+	var tracking redis.Conn
+	if inv.mode == InvalidateTracking {
+		id, err := redis.Int64(conn.Do("CLIENT", "ID"))
+		if err != nil {
+			return err
+		}
+		tracking = s.Pool.Get()
+		defer func() {
+			// Tracking is a per connection setting; reset it before
+			// the connection goes back to the pool.
+			if _, err := tracking.Do("CLIENT", "TRACKING", "OFF"); err != nil {
+				fmt.Printf("Error disabling client tracking: %v\n", err)
+			}
+			if err := tracking.Close(); err != nil {
+				fmt.Printf("Error closing connection: %v\n", err)
+			}
+		}()
+		args := []interface{}{"TRACKING", "ON", "REDIRECT", id, "BCAST"}
+		for _, prefix := range s.keyPrefixes() {
+			args = append(args, "PREFIX", prefix)
+		}
+		if _, err := tracking.Do("CLIENT", args...); err != nil {
+			return err
+		}
+		channel = trackingChannel
+	}
+
+	inv.mu.Lock()
+	if inv.closed.Load() {
+		inv.mu.Unlock()
+		return nil
+	}
+	if err := conn.Send("SUBSCRIBE", channel); err != nil {
+		inv.mu.Unlock()
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		inv.mu.Unlock()
+		return err
+	}
+	inv.conn = conn
+	inv.mu.Unlock()
+	defer func() {
+		inv.mu.Lock()
+		inv.conn = nil
+		inv.mu.Unlock()
+	}()
+
+	lost := make(chan error, 1)
+	if tracking != nil {
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go s.watchTracking(tracking, lost, stop, stopped)
+		// Wait for the watchdog before the tracking connection is
+		// released.
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+	}
+
+	for {
+		reply, err := redis.Values(conn.Receive())
+		if err != nil {
+			return err
+		}
+		var kind string
+		if _, err := redis.Scan(reply, &kind); err != nil {
+			return err
+		}
+		switch kind {
+		case "subscribe":
+			onReady()
+		case "unsubscribe":
+			// Sent by close, or by watchTracking.
+			select {
+			case err := <-lost:
+				return err
+			default:
+				return nil
+			}
+		case "message":
+			if len(reply) == 3 {
+				s.applyInvalidation(reply[2])
+			}
+		}
+	}
+}
+
+// watchTracking pings the tracking connection until stop is closed. When
+// a ping fails, it reports the error on lost and unsubscribes the
+// listener, which then returns it.
+func (s *RediStore) watchTracking(tracking redis.Conn, lost chan<- error, stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(trackingCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		_, err := tracking.Do("PING")
+		if err == nil {
+			continue
+		}
+		lost <- fmt.Errorf("tracking connection: %w", err)
+		inv := s.invalidator
+		inv.mu.Lock()
+		if inv.conn != nil {
+			if err := inv.conn.Send("UNSUBSCRIBE"); err == nil {
+				_ = inv.conn.Flush()
+			}
+		}
+		inv.mu.Unlock()
+		return
+	}
+}
+
+// applyInvalidation evicts the keys named in an invalidation message.
+func (s *RediStore) applyInvalidation(data interface{}) {
+	switch v := data.(type) {
+	case nil:
+		// CLIENT TRACKING sends a null payload on FLUSHALL / FLUSHDB.
+		s.cache.purge()
+	case []interface{}:
+		// CLIENT TRACKING sends the list of modified keys.
+		for _, k := range v {
+			if key, err := redis.String(k, nil); err == nil {
+				s.cache.invalidate(key)
+			}
+		}
+	case []byte:
+		// InvalidatePubSub messages are "<origin> <key>".
+		origin, key, ok := bytes.Cut(v, []byte(" "))
+		if !ok || string(origin) == s.invalidator.origin {
+			return
+		}
+		s.cache.invalidate(string(key))
+	}
+}
+
+// publishInvalidation tells other stores that keys have changed, using a
+// single pipelined round trip. It is a no-op unless the store uses
+// InvalidatePubSub.
+func (s *RediStore) publishInvalidation(conn redis.Conn, keys ...string) error {
+	if s.invalidator == nil || s.invalidator.mode != InvalidatePubSub || len(keys) == 0 {
+		return nil
+	}
+	for _, key := range keys {
+		if err := conn.Send("PUBLISH", s.invalidator.channel, s.invalidator.origin+" "+key); err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var firstErr error
+	for range keys {
+		if _, err := conn.Receive(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// stopInvalidation unsubscribes the listener and waits for it to exit.
+func (s *RediStore) stopInvalidation() {
+	inv := s.invalidator
+	if inv == nil || inv.closed.Swap(true) {
+		return
+	}
+	close(inv.stop)
+	inv.mu.Lock()
+	if inv.conn != nil {
+		// Send and Flush may be called concurrently with Receive.
+		if err := inv.conn.Send("UNSUBSCRIBE"); err == nil {
+			_ = inv.conn.Flush()
+		}
+	}
+	inv.mu.Unlock()
+	select {
+	case <-inv.done:
+	case <-time.After(5 * time.Second):
+		fmt.Println("redistore: timed out waiting for invalidation listener")
+	}
+}
@@ -0,0 +1,397 @@
+package redistore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+func TestLocalCache_LRU(t *testing.T) {
+	c := newLocalCache(2, time.Minute)
+	c.set("a", []byte("1"), 0)
+	c.set("b", []byte("2"), 0)
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	// b is now least recently used and must be evicted.
+	c.set("c", []byte("3"), 0)
+	if _, ok := c.get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("Expected c to be cached")
+	}
+
+	c.beginFill("a")
+	c.invalidate("a")
+	if _, ok := c.get("a"); ok {
+		t.Error("Expected a to be invalidated")
+	}
+	c.endFill("a", []byte("stale"), 0)
+	if _, ok := c.get("a"); ok {
+		t.Error("Expected stale write after invalidation to be dropped")
+	}
+
+	var nilCache *localCache
+	nilCache.set("a", []byte("1"), 0)
+	if _, ok := nilCache.get("a"); ok {
+		t.Error("Expected nil cache to be empty")
+	}
+}
+
+func TestLocalCache_SetDuringFill(t *testing.T) {
+	c := newLocalCache(10, time.Minute)
+	c.beginFill("a")
+	c.set("a", []byte("new"), 0)
+	c.endFill("a", []byte("old"), 0)
+	if data, ok := c.get("a"); !ok || string(data) != "new" {
+		t.Errorf("Expected the value set during the fill to be kept, got %q", data)
+	}
+
+	// Once the fill has ended, later fills are cached again.
+	c.beginFill("a")
+	c.endFill("a", []byte("newer"), 0)
+	if data, _ := c.get("a"); string(data) != "newer" {
+		t.Errorf("Expected a later fill to be cached, got %q", data)
+	}
+}
+
+func TestLocalCache_TTL(t *testing.T) {
+	c := newLocalCache(10, time.Millisecond)
+	c.set("a", []byte("1"), 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.get("a"); ok {
+		t.Error("Expected entry to expire")
+	}
+
+	// The Redis key expiring first caps the entry's lifetime.
+	c = newLocalCache(10, time.Minute)
+	c.set("a", []byte("1"), time.Millisecond)
+	c.beginFill("b")
+	c.endFill("b", []byte("2"), time.Millisecond)
+	c.set("c", []byte("3"), time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.get("a"); ok {
+		t.Error("Expected set entry to expire with its Redis key")
+	}
+	if _, ok := c.get("b"); ok {
+		t.Error("Expected filled entry to expire with its Redis key")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("Expected entry to be kept for the cache ttl")
+	}
+}
+
+func TestWithLocalCache_Invalid(t *testing.T) {
+	cfg := defaultConfig()
+	if err := WithLocalCache(0, time.Second)(cfg); err == nil {
+		t.Error("Expected error for zero cache size")
+	}
+	if err := WithLocalCache(10, 0)(cfg); err == nil {
+		t.Error("Expected error for zero cache ttl")
+	}
+	if err := WithCacheInvalidation(InvalidationMode(42))(cfg); err == nil {
+		t.Error("Expected error for unknown invalidation mode")
+	}
+	if err := WithInvalidationChannel("")(cfg); err == nil {
+		t.Error("Expected error for empty invalidation channel")
+	}
+}
+
+func TestLocalCache_PubSubInvalidation(t *testing.T) {
+	addr := setup()
+	newCachedStore := func() *RediStore {
+		store, err := NewStore(
+			[][]byte{[]byte(testHashKey)},
+			WithAddress("tcp", addr),
+			WithLocalCache(100, time.Minute),
+		)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return store
+	}
+	a, b := newCachedStore(), newCachedStore()
+	defer func() {
+		for _, store := range []*RediStore{a, b} {
+			if err := store.Close(); err != nil {
+				fmt.Printf("Error closing store: %v\n", err)
+			}
+		}
+	}()
+
+	session := sessions.NewSession(a, "cached")
+	session.Options = &sessions.Options{MaxAge: 60}
+	session.ID = newSessionID()
+	session.Values["v"] = 1
+	if err := a.save(context.Background(), session); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	read := func(store *RediStore) interface{} {
+		s := sessions.NewSession(store, "cached")
+		s.ID = session.ID
+		if ok, err := store.load(context.Background(), s); err != nil || !ok {
+			t.Fatalf("load failed: %v %v", ok, err)
+		}
+		return s.Values["v"]
+	}
+	// A load racing with the invalidation of the first save is not
+	// cached, so retry until the cache is populated.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v := read(b); v != 1 {
+			t.Fatalf("Expected 1, got %v", v)
+		}
+		if _, ok := b.cache.get(b.keyPrefix + session.ID); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected load to populate the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	session.Values["v"] = 2
+	if err := a.save(context.Background(), session); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for read(b) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected remote save to invalidate the cached session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLocalCache_KeyExpiry(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithLocalCache(100, time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	session := sessions.NewSession(store, "cached")
+	session.Options = &sessions.Options{MaxAge: 1}
+	session.ID = newSessionID()
+	if err := store.save(context.Background(), session); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	// Redis expires the key without any invalidation message, so the
+	// cached entry must not outlive it.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := sessions.NewSession(store, "cached")
+		s.ID = session.ID
+		ok, err := store.load(context.Background(), s)
+		if err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the cached session to expire with its Redis key")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// fakeTracking emulates CLIENT ID and CLIENT TRACKING, which the test
+// server doesn't implement, on the connections of a pool.
+type fakeTracking struct {
+	mu      sync.Mutex
+	enabled []*trackingConn // connections that turned tracking on
+	args    [][]interface{}
+}
+
+func (f *fakeTracking) tracking() ([]*trackingConn, [][]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*trackingConn(nil), f.enabled...), append([][]interface{}(nil), f.args...)
+}
+
+// trackingConn delivers messages published on the tracking channel the way
+// Redis delivers tracking notifications to RESP2 clients, as a list of
+// keys. Once broken, every command fails.
+type trackingConn struct {
+	redis.Conn
+	fake   *fakeTracking
+	broken atomic.Bool
+}
+
+var errBrokenConn = errors.New("broken connection")
+
+func (c *trackingConn) Err() error {
+	if c.broken.Load() {
+		return errBrokenConn
+	}
+	return c.Conn.Err()
+}
+
+func (c *trackingConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if c.broken.Load() {
+		return nil, errBrokenConn
+	}
+	if cmd == "CLIENT" && len(args) > 0 {
+		switch args[0] {
+		case "ID":
+			return int64(42), nil
+		case "TRACKING":
+			if args[1] == "ON" {
+				c.fake.mu.Lock()
+				c.fake.enabled = append(c.fake.enabled, c)
+				c.fake.args = append(c.fake.args, args)
+				c.fake.mu.Unlock()
+			}
+			return "OK", nil
+		}
+	}
+	return c.Conn.Do(cmd, args...)
+}
+
+func (c *trackingConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	if v, ok := reply.([]interface{}); ok && len(v) == 3 {
+		kind, _ := redis.String(v[0], nil)
+		channel, _ := redis.String(v[1], nil)
+		if kind == "message" && channel == trackingChannel {
+			v[2] = []interface{}{v[2]}
+		}
+	}
+	return reply, err
+}
+
+func TestLocalCache_TrackingInvalidation(t *testing.T) {
+	defer func(interval time.Duration) { trackingCheckInterval = interval }(trackingCheckInterval)
+	trackingCheckInterval = 10 * time.Millisecond
+	addr := setup()
+	fake := &fakeTracking{}
+	pool := &redis.Pool{
+		MaxIdle: 10,
+		Dial: func() (redis.Conn, error) {
+			conn, err := redis.Dial("tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			return &trackingConn{Conn: conn, fake: fake}, nil
+		},
+	}
+	cached, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithPool(pool),
+		WithKeyPrefix("tracked_"),
+		WithLocalCache(100, time.Minute),
+		WithCacheInvalidation(InvalidateTracking),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	other, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("tracked_"),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		for _, store := range []*RediStore{cached, other} {
+			if err := store.Close(); err != nil {
+				fmt.Printf("Error closing store: %v\n", err)
+			}
+		}
+	}()
+
+	enabled, args := fake.tracking()
+	if len(enabled) != 1 {
+		t.Fatalf("Expected tracking to be enabled once, got %d", len(enabled))
+	}
+	if want := fmt.Sprint([]interface{}{"TRACKING", "ON", "REDIRECT", int64(42), "BCAST", "PREFIX", "tracked_"}); fmt.Sprint(args[0]) != want {
+		t.Errorf("Expected CLIENT %v, got %v", want, args[0])
+	}
+
+	session := sessions.NewSession(cached, "cached")
+	session.Options = &sessions.Options{MaxAge: 60}
+	session.ID = newSessionID()
+	key := cached.keyPrefix + session.ID
+	read := func() interface{} {
+		s := sessions.NewSession(cached, "cached")
+		s.ID = session.ID
+		if ok, err := cached.load(context.Background(), s); err != nil || !ok {
+			t.Fatalf("load failed: %v %v", ok, err)
+		}
+		return s.Values["v"]
+	}
+	// notify caches the current value, saves v with the other store, which
+	// publishes nothing, and sends the tracking notification Redis would
+	// send for the write until the cached session is invalidated.
+	notify := func(v int) {
+		t.Helper()
+		read()
+		if _, ok := cached.cache.get(key); !ok {
+			t.Fatal("Expected load to populate the cache")
+		}
+		session.Values["v"] = v
+		if err := other.save(context.Background(), session); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+		conn := other.Pool.Get()
+		defer func() {
+			if err := conn.Close(); err != nil {
+				fmt.Printf("Error closing connection: %v\n", err)
+			}
+		}()
+		deadline := time.Now().Add(2 * time.Second)
+		for read() != v {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the notification to invalidate the cached session")
+			}
+			if _, err := conn.Do("PUBLISH", trackingChannel, key); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	session.Values["v"] = 1
+	if err := cached.save(context.Background(), session); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	notify(2)
+
+	// Losing the tracking connection alone re-enables tracking on a new
+	// connection.
+	enabled[0].broken.Store(true)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if enabled, _ = fake.tracking(); len(enabled) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected tracking to be enabled again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if enabled[1] == enabled[0] {
+		t.Error("Expected tracking to be enabled on a new connection")
+	}
+	notify(3)
+}
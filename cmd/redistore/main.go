@@ -0,0 +1,511 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Command redistore inspects and manages the sessions of a redistore
+// session store from the command line.
+//
+// Usage:
+//
+//	redistore [flags] <command> [arguments]
+//
+// The commands are:
+//
+//	list                      list session IDs and their TTL
+//	show <id>                 print a session's values as JSON, without
+//	                          the store's internal values
+//	ttl <id>                  print a session's remaining TTL
+//	delete <id>               delete a session
+//	purge --older-than <d>    delete sessions created more than d ago, as
+//	                          recorded by WithMetadata
+//	stats [--sample n]        print the number, sizes and TTLs of sessions
+//	export                    write all sessions to stdout as JSON lines
+//	import [--from gob|json]  read sessions written by export from stdin
+//	migrate --from-prefix p   copy sessions from another prefix or serializer
+//	decode-cookie <name> <v>  decode a cookie value and print its session
+//
+// The connection flags mirror the options of redistore.NewStore:
+//
+//	-addr host:port   Redis address (default "localhost:6379")
+//	-network tcp      network for -addr (default "tcp")
+//	-url redis://...  Redis URL, instead of -addr
+//	-username, -db
+//	-password s       Redis password (default $REDISTORE_PASSWORD, which
+//	                  keeps it out of the process list)
+//	-prefix session_  key prefix (default "session_")
+//	-serializer gob   gob or json (default "gob")
+//	-hash-secret s    secret of WithHashedKeys, if the store hashes its keys
+//	-key secret       cookie key, repeat for each key of each pair; values
+//	                  starting with "base64:" are decoded first
+//
+// Sessions serialized with gob can only be decoded if their value types
+// are registered, so custom types are only shown with the json serializer.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boj/redistore/v2"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// keyList collects repeated -key flags.
+type keyList [][]byte
+
+func (k *keyList) String() string {
+	return fmt.Sprintf("%d keys", len(*k))
+}
+
+func (k *keyList) Set(v string) error {
+	if rest, ok := strings.CutPrefix(v, "base64:"); ok {
+		b, err := base64.StdEncoding.DecodeString(rest)
+		if err != nil {
+			return fmt.Errorf("invalid base64 key: %w", err)
+		}
+		*k = append(*k, b)
+		return nil
+	}
+	*k = append(*k, []byte(v))
+	return nil
+}
+
+// config holds the global flags.
+type config struct {
+	addr       string
+	network    string
+	url        string
+	username   string
+	password   string
+	db         int
+	prefix     string
+	serializer string
+	hashSecret string
+	keys       keyList
+}
+
+// serializer returns the serializer with the given name.
+func serializer(name string) (redistore.SessionSerializer, error) {
+	switch name {
+	case "gob":
+		return redistore.GobSerializer{}, nil
+	case "json":
+		return redistore.JSONSerializer{}, nil
+	}
+	return nil, fmt.Errorf("unknown serializer %q", name)
+}
+
+func (c *config) options() ([]redistore.Option, error) {
+	opts := []redistore.Option{redistore.WithKeyPrefix(c.prefix)}
+	if c.url != "" {
+		opts = append(opts, redistore.WithURL(c.url))
+	} else {
+		opts = append(opts,
+			redistore.WithAddress(c.network, c.addr),
+			redistore.WithAuth(c.username, c.password),
+			redistore.WithDBNum(c.db),
+		)
+	}
+	if c.hashSecret != "" {
+		opts = append(opts, redistore.WithHashedKeys([]byte(c.hashSecret)))
+	}
+	ser, err := serializer(c.serializer)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, redistore.WithSerializer(ser)), nil
+}
+
+// passwordEnv is the environment variable read when -password is not set.
+const passwordEnv = "REDISTORE_PASSWORD"
+
+// flagSet reports whether the flag name was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: redistore [flags] <list|show|ttl|delete|purge|stats|export|import|migrate|decode-cookie> [arguments]")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// run executes the command line args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var cfg config
+	fs := flag.NewFlagSet("redistore", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&cfg.addr, "addr", "localhost:6379", "Redis address")
+	fs.StringVar(&cfg.network, "network", "tcp", "network for -addr")
+	fs.StringVar(&cfg.url, "url", "", "Redis URL, instead of -addr")
+	fs.StringVar(&cfg.username, "username", "", "Redis username")
+	fs.StringVar(&cfg.password, "password", "", "Redis password (default $"+passwordEnv+")")
+	fs.IntVar(&cfg.db, "db", 0, "Redis database index")
+	fs.StringVar(&cfg.prefix, "prefix", "session_", "session key prefix")
+	fs.StringVar(&cfg.serializer, "serializer", "gob", "session serializer: gob or json")
+	fs.StringVar(&cfg.hashSecret, "hash-secret", "", "secret of WithHashedKeys, if used")
+	fs.Var(&cfg.keys, "key", "cookie key, repeated for each key of each pair")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			usage(stdout, fs)
+			return 0
+		}
+		fmt.Fprintln(stderr, err)
+		usage(stderr, fs)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		usage(stderr, fs)
+		return 2
+	}
+	if !flagSet(fs, "password") {
+		cfg.password = os.Getenv(passwordEnv)
+	}
+
+	opts, err := cfg.options()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	keys := cfg.keys
+	if len(keys) == 0 {
+		// Keys are only needed to decode cookies.
+		keys = redistore.Keys(securecookie.GenerateRandomKey(32))
+	}
+	store, err := redistore.NewStore(keys, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Fprintf(stderr, "Error closing store: %v\n", err)
+		}
+	}()
+
+	cmd := &command{store: store, cfg: &cfg, in: stdin, out: stdout}
+	name, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch name {
+	case "list":
+		err = cmd.list(cmdArgs)
+	case "show":
+		err = cmd.show(cmdArgs)
+	case "ttl":
+		err = cmd.ttl(cmdArgs)
+	case "delete":
+		err = cmd.delete(cmdArgs)
+	case "purge":
+		err = cmd.purge(cmdArgs)
+	case "stats":
+		err = cmd.stats(cmdArgs)
+	case "export":
+		err = cmd.export(cmdArgs)
+	case "import":
+		err = cmd.importSessions(cmdArgs)
+	case "migrate":
+		err = cmd.migrate(cmdArgs)
+	case "decode-cookie":
+		if len(cfg.keys) == 0 {
+			err = usageError("decode-cookie requires -key")
+			break
+		}
+		err = cmd.decodeCookie(cmdArgs)
+	default:
+		err = usageError(fmt.Sprintf("unknown command %q", name))
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "redistore %s: %v\n", name, err)
+		var uerr usageError
+		if errors.As(err, &uerr) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// usageError reports invalid arguments.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// command runs subcommands against a store.
+type command struct {
+	store *redistore.RediStore
+	cfg   *config
+	in    io.Reader
+	out   io.Writer
+}
+
+func oneID(args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", usageError("expected a single session ID")
+	}
+	return args[0], nil
+}
+
+func (c *command) list(args []string) error {
+	if len(args) != 0 {
+		return usageError("list takes no arguments")
+	}
+	seq, errFn := c.store.SessionIDs(context.Background())
+	for id, ttl := range seq {
+		fmt.Fprintf(c.out, "%s\t%s\n", id, ttl)
+	}
+	return errFn()
+}
+
+func (c *command) show(args []string) error {
+	id, err := oneID(args)
+	if err != nil {
+		return err
+	}
+	results, err := c.store.LoadMany([]string{id})
+	if err != nil {
+		return err
+	}
+	if results[0].Err != nil {
+		return results[0].Err
+	}
+	if !results[0].Found {
+		return redistore.ErrSessionNotFound
+	}
+	return c.printSession(results[0].Session)
+}
+
+// printSession writes the session ID and values as indented JSON, leaving
+// out the values the store keeps for itself, such as CSRF secrets.
+func (c *command) printSession(session *sessions.Session) error {
+	values := make(map[string]interface{}, len(session.Values))
+	for k, v := range session.Values {
+		if redistore.IsInternalValue(k) {
+			continue
+		}
+		// Values that JSON can't represent are shown in their Go syntax.
+		if _, err := json.Marshal(v); err != nil {
+			v = fmt.Sprintf("%#v", v)
+		}
+		values[fmt.Sprint(k)] = v
+	}
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"id":     session.ID,
+		"values": values,
+	})
+}
+
+func (c *command) ttl(args []string) error {
+	id, err := oneID(args)
+	if err != nil {
+		return err
+	}
+	ttl, err := c.store.TTL(id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, ttl.Round(time.Second))
+	return nil
+}
+
+func (c *command) delete(args []string) error {
+	id, err := oneID(args)
+	if err != nil {
+		return err
+	}
+	return c.store.DeleteByID(id)
+}
+
+func (c *command) purge(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	olderThan := fs.Duration("older-than", 0, "delete sessions created more than this long ago")
+	dryRun := fs.Bool("dry-run", false, "only print the sessions that would be deleted")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if *olderThan <= 0 || fs.NArg() != 0 {
+		return usageError("purge requires a positive --older-than duration")
+	}
+
+	cutoff := time.Now().Add(-*olderThan)
+	seq, errFn := c.store.SessionIDs(context.Background())
+	deleted, unknown := 0, 0
+	for id := range seq {
+		// Only metadata records when a session was created; the idle time
+		// of its key is reset by every read.
+		md, err := c.store.Metadata(id)
+		if errors.Is(err, redistore.ErrSessionNotFound) {
+			unknown++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !md.Created.Before(cutoff) {
+			continue
+		}
+		if *dryRun {
+			fmt.Fprintln(c.out, id)
+			deleted++
+			continue
+		}
+		switch err := c.store.DeleteByID(id); {
+		case err == nil:
+			deleted++
+		case !errors.Is(err, redistore.ErrSessionNotFound):
+			return err
+		}
+	}
+	if err := errFn(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "%d sessions deleted\n", deleted)
+	if unknown > 0 {
+		return fmt.Errorf("%d sessions kept because they have no metadata; "+
+			"--older-than needs sessions saved with WithMetadata", unknown)
+	}
+	return nil
+}
+
+func (c *command) stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	sample := fs.Int("sample", 0, "examine at most this many sessions, 0 for all")
+	rate := fs.Int("rate", 2000, "maximum number of sessions examined per second")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() != 0 {
+		return usageError("stats takes no arguments")
+	}
+	stats, err := c.store.Stats(context.Background(),
+		redistore.WithStatsSample(*sample), redistore.WithStatsRate(*rate))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "sessions\t%d\n", stats.Count)
+	if stats.Sampled {
+		fmt.Fprintln(c.out, "sampled\ttrue")
+	}
+	fmt.Fprintf(c.out, "bytes\t%d\n", stats.TotalBytes)
+	for _, p := range stats.Sizes {
+		fmt.Fprintf(c.out, "size_p%g\t%d", p.Percentile, p.Bytes)
+		if stats.MaxLength > 0 {
+			fmt.Fprintf(c.out, "\t%.1f%% of %d", p.OfMaxLength*100, stats.MaxLength)
+		}
+		fmt.Fprintln(c.out)
+	}
+	for _, b := range stats.TTLs {
+		if b.Max == 0 {
+			fmt.Fprintf(c.out, "ttl_longer\t%d\n", b.Count)
+			continue
+		}
+		fmt.Fprintf(c.out, "ttl_under_%s\t%d\n", b.Max, b.Count)
+	}
+	if stats.NoExpiry > 0 {
+		fmt.Fprintf(c.out, "ttl_none\t%d\n", stats.NoExpiry)
+	}
+	if stats.Skipped > 0 {
+		fmt.Fprintf(c.out, "skipped\t%d\n", stats.Skipped)
+	}
+	return nil
+}
+
+func (c *command) export(args []string) error {
+	if len(args) != 0 {
+		return usageError("export takes no arguments")
+	}
+	_, err := c.store.Export(context.Background(), c.out)
+	return err
+}
+
+func (c *command) importSessions(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	from := fs.String("from", "", "serializer of the exported sessions, to re-encode them with -serializer")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() != 0 {
+		return usageError("import reads sessions from stdin and takes no arguments")
+	}
+	var opts []redistore.ImportOption
+	if *from != "" {
+		ser, err := serializer(*from)
+		if err != nil {
+			return usageError(err.Error())
+		}
+		opts = append(opts, redistore.WithImportSerializer(ser))
+	}
+	n, err := c.store.Import(context.Background(), c.in, opts...)
+	fmt.Fprintf(c.out, "%d sessions imported\n", n)
+	return err
+}
+
+func (c *command) migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fromPrefix := fs.String("from-prefix", "", "key prefix to migrate from")
+	from := fs.String("from", "", "serializer to migrate from, default -serializer")
+	overwrite := fs.Bool("overwrite", false, "overwrite sessions existing under -prefix")
+	deleteSource := fs.Bool("delete-source", false, "delete sessions from the old prefix once copied")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if *fromPrefix == "" || fs.NArg() != 0 {
+		return usageError("migrate requires --from-prefix")
+	}
+	if *from == "" {
+		*from = c.cfg.serializer
+	}
+	ser, err := serializer(*from)
+	if err != nil {
+		return usageError(err.Error())
+	}
+	var opts []redistore.MigrateOption
+	if *overwrite {
+		opts = append(opts, redistore.WithMigrationOverwrite())
+	}
+	if *deleteSource {
+		opts = append(opts, redistore.WithMigrationDeleteSource())
+	}
+	result, err := c.store.Migrate(context.Background(), *fromPrefix, ser, opts...)
+	if result != nil {
+		fmt.Fprintf(c.out, "%d migrated, %d skipped, %d failed\n",
+			result.Migrated, result.Skipped, result.Failed)
+	}
+	return err
+}
+
+func (c *command) decodeCookie(args []string) error {
+	if len(args) != 2 {
+		return usageError("decode-cookie expects a cookie name and value")
+	}
+	name, value := args[0], args[1]
+	var id string
+	if err := securecookie.DecodeMulti(name, value, &id, c.store.Codecs...); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "session ID: %s\n", id)
+	return c.show([]string{id})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boj/redistore/v2"
+)
+
+const testHashKey = "secret-key-for-tests-0123456789ab"
+
+func redisAddr() string {
+	host := os.Getenv("REDIS_HOST")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	port := os.Getenv("REDIS_PORT")
+	if port == "" {
+		port = "6379"
+	}
+	return fmt.Sprintf("%s:%s", host, port)
+}
+
+func runCmd(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	return runCmdInput(t, "", args...)
+}
+
+func runCmdInput(t *testing.T, input string, args ...string) (string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(input), &stdout, &stderr)
+	if code != 0 {
+		t.Logf("stderr: %s", stderr.String())
+	}
+	return stdout.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	addr := redisAddr()
+	prefix := "cli_test_" + strings.ToLower(t.Name()) + "_"
+	store, err := redistore.NewStore(
+		redistore.Keys([]byte(testHashKey)),
+		redistore.WithAddress("tcp", addr),
+		redistore.WithKeyPrefix(prefix),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.Purge(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost/", nil)
+	rsp := httptest.NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["name"] = "alice"
+	if _, err := store.CSRFToken(session); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	cookie := rsp.Result().Cookies()[0]
+
+	common := []string{"-addr", addr, "-prefix", prefix}
+	cmd := func(args ...string) []string { return append(append([]string{}, common...), args...) }
+
+	if out, code := runCmd(t, cmd("list")...); code != 0 || !strings.HasPrefix(out, session.ID+"\t") {
+		t.Errorf("list: expected %s, got %q (exit %d)", session.ID, out, code)
+	}
+	if out, code := runCmd(t, cmd("show", session.ID)...); code != 0 || !strings.Contains(out, `"alice"`) || strings.Contains(out, "_redistore_") {
+		t.Errorf("show: expected alice without internal values, got %q (exit %d)", out, code)
+	}
+	if out, code := runCmd(t, cmd("ttl", session.ID)...); code != 0 || strings.TrimSpace(out) == "0s" {
+		t.Errorf("ttl: got %q (exit %d)", out, code)
+	}
+	if out, code := runCmd(t, cmd("stats")...); code != 0 || !strings.Contains(out, "sessions\t1\n") {
+		t.Errorf("stats: got %q (exit %d)", out, code)
+	}
+	out, code := runCmd(t, append(cmd("-key", testHashKey), "decode-cookie", cookie.Name, cookie.Value)...)
+	if code != 0 || !strings.Contains(out, "session ID: "+session.ID) || !strings.Contains(out, `"alice"`) || strings.Contains(out, "_redistore_") {
+		t.Errorf("decode-cookie: got %q (exit %d)", out, code)
+	}
+	if _, code := runCmd(t, cmd("decode-cookie", cookie.Name, cookie.Value)...); code != 2 {
+		t.Errorf("decode-cookie without keys: expected exit 2, got %d", code)
+	}
+	if _, code := runCmd(t, cmd("purge")...); code != 2 {
+		t.Errorf("purge without --older-than: expected exit 2, got %d", code)
+	}
+	// The session has no metadata, so its age is unknown.
+	if out, code := runCmd(t, cmd("purge", "--older-than", "1h")...); code != 1 || out != "0 sessions deleted\n" {
+		t.Errorf("purge: expected nothing deleted and exit 1, got %q (exit %d)", out, code)
+	}
+	exported, code := runCmd(t, cmd("export")...)
+	if code != 0 || strings.Count(exported, "\n") != 2 {
+		t.Errorf("export: expected a header and a session, got %q (exit %d)", exported, code)
+	}
+	into := []string{"-addr", addr, "-prefix", prefix + "copy_", "-serializer", "json"}
+	out, code = runCmdInput(t, exported, append(into, "import", "--from", "gob")...)
+	if code != 0 || out != "1 sessions imported\n" {
+		t.Errorf("import: got %q (exit %d)", out, code)
+	}
+	if out, code := runCmd(t, append(into, "show", session.ID)...); code != 0 || !strings.Contains(out, `"alice"`) {
+		t.Errorf("show imported: expected alice, got %q (exit %d)", out, code)
+	}
+	into = []string{"-addr", addr, "-prefix", prefix + "migrated_", "-serializer", "json"}
+	out, code = runCmd(t, append(into, "migrate", "--from-prefix", prefix+"copy_")...)
+	if code != 0 || out != "1 migrated, 0 skipped, 0 failed\n" {
+		t.Errorf("migrate: got %q (exit %d)", out, code)
+	}
+	if _, code := runCmd(t, cmd("delete", session.ID)...); code != 0 {
+		t.Errorf("delete: exit %d", code)
+	}
+	if _, code := runCmd(t, cmd("show", session.ID)...); code != 1 {
+		t.Errorf("show after delete: expected exit 1, got %d", code)
+	}
+	if _, code := runCmd(t, cmd("bogus")...); code != 2 {
+		t.Errorf("unknown command: expected exit 2, got %d", code)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	addr := redisAddr()
+	prefix := "cli_test_" + strings.ToLower(t.Name()) + "_"
+	store, err := redistore.NewStore(
+		redistore.Keys([]byte(testHashKey)),
+		redistore.WithAddress("tcp", addr),
+		redistore.WithKeyPrefix(prefix),
+		redistore.WithMetadata(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.Purge(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 2)
+	for i := range ids {
+		req := httptest.NewRequest("GET", "http://localhost/", nil)
+		session, err := store.New(req, "session-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		session.Values["n"] = i
+		if err := session.Save(req, httptest.NewRecorder()); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = session.ID
+	}
+	// Backdate the first session, and add an old one that can't be
+	// deserialized.
+	conn := store.Pool.Get()
+	defer conn.Close()
+	old := time.Now().Add(-time.Hour).UnixMilli()
+	if _, err := conn.Do("HSET", "redistore:meta:"+prefix+ids[0], "created", old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Do("SETEX", prefix+"garbage", 60, "not gob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Do("HSET", "redistore:meta:"+prefix+"garbage", "created", old); err != nil {
+		t.Fatal(err)
+	}
+
+	common := []string{"-addr", addr, "-prefix", prefix}
+	cmd := func(args ...string) []string { return append(append([]string{}, common...), args...) }
+	out, code := runCmd(t, cmd("list")...)
+	if code != 0 || strings.Count(out, "\n") != 3 || !strings.Contains(out, "garbage\t") {
+		t.Errorf("list: expected 3 sessions, got %q (exit %d)", out, code)
+	}
+	out, code = runCmd(t, cmd("purge", "--older-than", "1m", "--dry-run")...)
+	if code != 0 || !strings.HasSuffix(out, "2 sessions deleted\n") || strings.Contains(out, ids[1]) {
+		t.Errorf("purge --dry-run: got %q (exit %d)", out, code)
+	}
+	if out, code := runCmd(t, cmd("purge", "--older-than", "1m")...); code != 0 || out != "2 sessions deleted\n" {
+		t.Errorf("purge: expected 2 sessions deleted, got %q (exit %d)", out, code)
+	}
+	if out, code := runCmd(t, cmd("list")...); code != 0 || !strings.HasPrefix(out, ids[1]+"\t") || strings.Count(out, "\n") != 1 {
+		t.Errorf("list after purge: expected only %s, got %q (exit %d)", ids[1], out, code)
+	}
+}
+
+func TestKeyList(t *testing.T) {
+	var keys keyList
+	if err := keys.Set("plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Set("base64:c2VjcmV0"); err != nil {
+		t.Fatal(err)
+	}
+	if string(keys[0]) != "plain" || string(keys[1]) != "secret" {
+		t.Errorf("unexpected keys %q", keys)
+	}
+	if err := keys.Set("base64:!!"); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+}
+
+func TestPasswordEnv(t *testing.T) {
+	addr := redisAddr()
+	// The test server has no password, so sending one fails.
+	t.Setenv("REDISTORE_PASSWORD", "from-env")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-addr", addr, "list"}, strings.NewReader(""), &stdout, &stderr); code == 0 {
+		t.Error("Expected the password from REDISTORE_PASSWORD to be sent")
+	}
+	// An explicit -password wins over the environment.
+	if _, code := runCmd(t, "-addr", addr, "-password", "", "list"); code != 0 {
+		t.Errorf("Expected -password to override REDISTORE_PASSWORD, got exit code %d", code)
+	}
+}
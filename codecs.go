@@ -0,0 +1,51 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"errors"
+
+	"github.com/gorilla/securecookie"
+)
+
+// errNoCodecs mirrors the error securecookie returns when no codecs are
+// configured.
+var errNoCodecs = errors.New("securecookie: no codecs provided")
+
+// decodeCookie behaves like securecookie.DecodeMulti, but also returns the
+// index in s.codecs() of the codec that decoded the value, or -1.
+func (s *RediStore) decodeCookie(name, value string, dst interface{}) (int, error) {
+	codecs := s.codecs()
+	if len(codecs) == 0 {
+		return -1, errNoCodecs
+	}
+	var errs securecookie.MultiError
+	for i, codec := range codecs {
+		err := codec.Decode(name, value, dst)
+		if err == nil {
+			return i, nil
+		}
+		errs = append(errs, err)
+	}
+	return -1, errs
+}
+
+// encodeCookie behaves like securecookie.EncodeMulti, but also returns the
+// index in s.codecs() of the codec that encoded the value, or -1.
+func (s *RediStore) encodeCookie(name string, value interface{}) (string, int, error) {
+	codecs := s.codecs()
+	if len(codecs) == 0 {
+		return "", -1, errNoCodecs
+	}
+	var errs securecookie.MultiError
+	for i, codec := range codecs {
+		encoded, err := codec.Encode(name, value)
+		if err == nil {
+			return encoded, i, nil
+		}
+		errs = append(errs, err)
+	}
+	return "", -1, errs
+}
@@ -0,0 +1,183 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// csrfValueKey is the session.Values key holding the CSRF secret of the
+// session, in the manner of userValueKey.
+const csrfValueKey = "_redistore_csrf"
+
+// csrfSecretLen is the length in bytes of CSRF secrets and masks.
+const csrfSecretLen = 32
+
+// Defaults for WithCSRFHeader and WithCSRFField.
+const (
+	defaultCSRFHeader = "X-CSRF-Token"
+	defaultCSRFField  = "csrf_token"
+)
+
+var (
+	// ErrCSRFTokenMissing is returned by VerifyCSRF when the request
+	// carries no token.
+	ErrCSRFTokenMissing = errors.New("redistore: missing CSRF token")
+
+	// ErrCSRFTokenInvalid is returned by VerifyCSRF when the token of the
+	// request doesn't match the session's.
+	ErrCSRFTokenInvalid = errors.New("redistore: invalid CSRF token")
+)
+
+// WithCSRFHeader sets the request header VerifyCSRF reads the token from.
+// Default is "X-CSRF-Token".
+func WithCSRFHeader(name string) Option {
+	return func(cfg *storeConfig) error {
+		if name == "" {
+			return errors.New("CSRF header cannot be empty")
+		}
+		cfg.csrfHeader = name
+		return nil
+	}
+}
+
+// WithCSRFField sets the form field VerifyCSRF reads the token from when
+// the header is absent. Default is "csrf_token".
+func WithCSRFField(name string) Option {
+	return func(cfg *storeConfig) error {
+		if name == "" {
+			return errors.New("CSRF field cannot be empty")
+		}
+		cfg.csrfField = name
+		return nil
+	}
+}
+
+// CSRFToken returns a token for the session to embed in forms or send in
+// the CSRF header. A secret is generated on first use and kept in the
+// session, which must then be saved. Each call masks the secret with a
+// fresh random pad, so that tokens differ on every response and can't be
+// recovered through compression side channels such as BREACH.
+//
+// The secret is replaced by RegenerateID, invalidating earlier tokens.
+//
+// Example:
+//
+//	token, err := store.CSRFToken(session)
+//	if err != nil {
+//	    http.Error(w, err.Error(), http.StatusInternalServerError)
+//	    return
+//	}
+//	if err := session.Save(r, w); err != nil { ... }
+//	tmpl.Execute(w, map[string]string{"CSRFToken": token})
+func (s *RediStore) CSRFToken(session *sessions.Session) (string, error) {
+	secret, err := csrfSecret(session)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		if secret, err = newCSRFSecret(session); err != nil {
+			return "", err
+		}
+	}
+	token := make([]byte, 2*csrfSecretLen)
+	pad, masked := token[:csrfSecretLen], token[csrfSecretLen:]
+	if _, err := rand.Read(pad); err != nil {
+		return "", err
+	}
+	subtle.XORBytes(masked, pad, secret)
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// VerifyCSRF checks the token sent with r, in the CSRF header or else the
+// CSRF form field, against the session's secret. It returns
+// ErrCSRFTokenMissing if there is no token and ErrCSRFTokenInvalid if it
+// doesn't match, including when the session has no secret yet.
+func (s *RediStore) VerifyCSRF(r *http.Request, session *sessions.Session) error {
+	sent := r.Header.Get(s.csrfHeader)
+	if sent == "" {
+		sent = r.PostFormValue(s.csrfField)
+	}
+	if sent == "" {
+		return ErrCSRFTokenMissing
+	}
+	secret, err := csrfSecret(session)
+	if err != nil || secret == nil {
+		return ErrCSRFTokenInvalid
+	}
+	token, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(token) != 2*csrfSecretLen {
+		return ErrCSRFTokenInvalid
+	}
+	unmasked := make([]byte, csrfSecretLen)
+	subtle.XORBytes(unmasked, token[:csrfSecretLen], token[csrfSecretLen:])
+	if subtle.ConstantTimeCompare(unmasked, secret) != 1 {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+// CSRFProtect returns a handler that loads the session name and rejects
+// requests with unsafe methods, all but GET, HEAD, OPTIONS and TRACE,
+// whose CSRF token fails VerifyCSRF, with 403 Forbidden.
+//
+// Example:
+//
+//	mux.Handle("/account", store.CSRFProtect("session-key", accountHandler))
+func (s *RediStore) CSRFProtect(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		default:
+			session, err := s.Get(r, name)
+			if err == nil {
+				err = s.VerifyCSRF(r, session)
+			}
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfSecret returns the CSRF secret of the session, or nil if it has none.
+func csrfSecret(session *sessions.Session) ([]byte, error) {
+	encoded, _ := session.Values[csrfValueKey].(string)
+	if encoded == "" {
+		return nil, nil
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(secret) != csrfSecretLen {
+		return nil, errors.New("redistore: malformed CSRF secret in session")
+	}
+	return secret, nil
+}
+
+// newCSRFSecret stores a new CSRF secret in the session.
+func newCSRFSecret(session *sessions.Session) ([]byte, error) {
+	secret := make([]byte, csrfSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	session.Values[csrfValueKey] = base64.RawURLEncoding.EncodeToString(secret)
+	return secret, nil
+}
+
+// rotateCSRF replaces the CSRF secret of a session that has one.
+func rotateCSRF(session *sessions.Session) error {
+	if _, ok := session.Values[csrfValueKey]; !ok {
+		return nil
+	}
+	_, err := newCSRFSecret(session)
+	return err
+}
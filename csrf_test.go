@@ -0,0 +1,172 @@
+package redistore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := store.CSRFToken(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := store.CSRFToken(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == other {
+		t.Error("Expected tokens to be masked differently on each call")
+	}
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	cookie := getCookies(t, rsp)[0]
+
+	post := func(header, field string) *http.Request {
+		form := url.Values{}
+		if field != "" {
+			form.Set("csrf_token", field)
+		}
+		req, _ := http.NewRequestWithContext(context.Background(), "POST",
+			"http://localhost:8080/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Cookie", cookie)
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		return req
+	}
+	session, err = store.New(post("", ""), "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[csrfSecretLen] ^= 1 // first byte of the masked secret
+	tampered := base64.RawURLEncoding.EncodeToString(raw)
+	for _, tc := range []struct {
+		name string
+		req  *http.Request
+		want error
+	}{
+		{"header", post(token, ""), nil},
+		{"form field", post("", other), nil},
+		{"missing", post("", ""), ErrCSRFTokenMissing},
+		{"tampered", post(tampered, ""), ErrCSRFTokenInvalid},
+		{"garbage", post("not-a-token", ""), ErrCSRFTokenInvalid},
+	} {
+		if err := store.VerifyCSRF(tc.req, session); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	// Regenerating the ID invalidates earlier tokens.
+	if err := store.RegenerateID(req, NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.VerifyCSRF(post(token, ""), session); !errors.Is(err, ErrCSRFTokenInvalid) {
+		t.Errorf("Expected the token to be rotated, got %v", err)
+	}
+	fresh, err := store.CSRFToken(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.VerifyCSRF(post(fresh, ""), session); err != nil {
+		t.Errorf("Expected the new token to verify, got %v", err)
+	}
+}
+
+func TestCSRFProtect(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithCSRFHeader("X-Token"),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := store.CSRFToken(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	cookie := getCookies(t, rsp)[0]
+
+	handler := store.CSRFProtect("session-key", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(method, token string) int {
+		req, _ := http.NewRequestWithContext(
+			context.Background(), method, "http://localhost:8080/", nil)
+		req.Header.Add("Cookie", cookie)
+		if token != "" {
+			req.Header.Set("X-Token", token)
+		}
+		rsp := NewRecorder()
+		handler.ServeHTTP(rsp, req)
+		return rsp.Code
+	}
+	if code := serve("GET", ""); code != http.StatusNoContent {
+		t.Errorf("Expected safe methods to pass, got %d", code)
+	}
+	if code := serve("POST", ""); code != http.StatusForbidden {
+		t.Errorf("Expected a POST without token to be forbidden, got %d", code)
+	}
+	if code := serve("DELETE", token); code != http.StatusNoContent {
+		t.Errorf("Expected a DELETE with token to pass, got %d", code)
+	}
+}
+
+func TestWithCSRF_Invalid(t *testing.T) {
+	if err := WithCSRFHeader("")(defaultConfig()); err == nil {
+		t.Error("Expected error for empty header")
+	}
+	if err := WithCSRFField("")(defaultConfig()); err == nil {
+		t.Error("Expected error for empty field")
+	}
+}
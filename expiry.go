@@ -0,0 +1,93 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// maxAgeValueKey is the session.Values key holding the MaxAge a session
+// was saved with, when it differs from the default of its profile, in the
+// manner of userValueKey.
+const maxAgeValueKey = "_redistore_max_age"
+
+// ErrCookieExpired is returned by New, subject to WithInvalidCookiePolicy,
+// when the signed timestamp of a cookie is older than the MaxAge of its
+// session.
+var ErrCookieExpired = errors.New("redistore: session cookie expired")
+
+// withoutMaxAge disables the timestamp check of codecs built by the store,
+// which checks each session's own MaxAge instead, see checkExpiry.
+func withoutMaxAge(codecs []securecookie.Codec) []securecookie.Codec {
+	for _, c := range codecs {
+		if sc, ok := c.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(0)
+		}
+	}
+	return codecs
+}
+
+// recordMaxAge keeps the MaxAge of a session being saved in its values,
+// so that it applies again when the session is loaded.
+func (s *RediStore) recordMaxAge(session *sessions.Session) {
+	if session.Options.MaxAge == s.profile(session.Name()).options.MaxAge {
+		delete(session.Values, maxAgeValueKey)
+		return
+	}
+	session.Values[maxAgeValueKey] = session.Options.MaxAge
+}
+
+// restoreMaxAge sets the MaxAge of a loaded session to the one it was
+// saved with.
+func restoreMaxAge(session *sessions.Session) {
+	switch age := session.Values[maxAgeValueKey].(type) {
+	case int:
+		session.Options.MaxAge = age
+	case float64: // JSONSerializer
+		session.Options.MaxAge = int(age)
+	}
+}
+
+// checkExpiry restores the MaxAge of a loaded session and checks the
+// signed timestamp of its cookie against it, or against the Redis TTL of
+// session cookies. An expired session is reset to an empty new session.
+func (s *RediStore) checkExpiry(session *sessions.Session, value string) error {
+	restoreMaxAge(session)
+	issued, ok := cookieTimestamp(value)
+	if !ok || time.Since(issued) <= time.Duration(s.ttl(session))*time.Second {
+		return nil
+	}
+	session.Values = make(map[interface{}]interface{})
+	session.IsNew = true
+	return ErrCookieExpired
+}
+
+// cookieTimestamp returns the time a cookie value was encoded by
+// securecookie. The value must have been decoded successfully, which
+// authenticates the timestamp. It returns false for values of other
+// codecs.
+func cookieTimestamp(value string) (time.Time, bool) {
+	b, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// The decoded value is "timestamp|payload|mac".
+	ts, _, ok := bytes.Cut(b, []byte("|"))
+	if !ok {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(string(ts), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
@@ -0,0 +1,126 @@
+package redistore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestPerSessionMaxAge(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	conn := store.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	const rememberMe = 90 * 24 * 3600
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Options.MaxAge = rememberMe
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The MaxAge comes back with the session and is kept on the next save.
+	req.Header.Add("Cookie", getCookies(t, rsp)[0])
+	loaded, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Options.MaxAge != rememberMe {
+		t.Errorf("Expected MaxAge %d, got %d", rememberMe, loaded.Options.MaxAge)
+	}
+	if err := loaded.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := redis.Int(conn.Do("TTL", "session_"+loaded.ID)); err != nil || ttl != rememberMe {
+		t.Errorf("Expected TTL %d, got %d (%v)", rememberMe, ttl, err)
+	}
+
+	// Back to the default, the marker is dropped.
+	loaded.Options.MaxAge = store.Options.MaxAge
+	if err := loaded.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Values[maxAgeValueKey]; ok {
+		t.Error("Expected no MaxAge marker for the default MaxAge")
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	store := &RediStore{Options: &sessions.Options{MaxAge: 60}, DefaultMaxAge: 1200}
+	cookie := func(age time.Duration) string {
+		value := fmt.Sprintf("%d|payload|mac", time.Now().Add(-age).Unix())
+		return base64.URLEncoding.EncodeToString([]byte(value))
+	}
+	newSession := func(maxAge int) *sessions.Session {
+		session := sessions.NewSession(store, "session-key")
+		session.Options = &sessions.Options{MaxAge: store.Options.MaxAge}
+		session.Values["name"] = "alice"
+		if maxAge != 0 {
+			session.Values[maxAgeValueKey] = maxAge
+		}
+		return session
+	}
+
+	if err := store.checkExpiry(newSession(0), cookie(30*time.Second)); err != nil {
+		t.Errorf("Expected a fresh cookie to pass, got %v", err)
+	}
+	session := newSession(0)
+	if err := store.checkExpiry(session, cookie(2*time.Minute)); !errors.Is(err, ErrCookieExpired) {
+		t.Errorf("Expected ErrCookieExpired, got %v", err)
+	}
+	if len(session.Values) != 0 || !session.IsNew {
+		t.Error("Expected an expired session to be reset")
+	}
+	if err := store.checkExpiry(newSession(3600), cookie(2*time.Minute)); err != nil {
+		t.Errorf("Expected the session's own MaxAge to apply, got %v", err)
+	}
+	// Session cookies expire with DefaultMaxAge, like their Redis key.
+	session = newSession(0)
+	session.Options.MaxAge = 0
+	if err := store.checkExpiry(session, cookie(10*time.Minute)); err != nil {
+		t.Errorf("Expected a session cookie within DefaultMaxAge to pass, got %v", err)
+	}
+	if err := store.checkExpiry(newSession(0), "not a securecookie value"); err != nil {
+		t.Errorf("Expected values of other codecs to be left alone, got %v", err)
+	}
+}
+
+func TestCookieTimestamp(t *testing.T) {
+	encoded, err := securecookie.New([]byte(testHashKey), nil).Encode("name", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, ok := cookieTimestamp(encoded)
+	if !ok || time.Since(issued) > time.Minute {
+		t.Errorf("Expected the encoding time, got %v", issued)
+	}
+}
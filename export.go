@@ -0,0 +1,252 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// exportFormat and exportVersion identify the stream written by Export.
+const (
+	exportFormat  = "redistore"
+	exportVersion = 1
+)
+
+// exportHeader is the first line of an export stream.
+type exportHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	Prefix  string `json:"prefix"`
+}
+
+// exportRecord is a session in an export stream. TTL is the remaining time
+// to live in milliseconds, 0 for a key without expiry. Data is the
+// serialized payload, base64 encoded by encoding/json.
+type exportRecord struct {
+	ID   string `json:"id"`
+	TTL  int64  `json:"ttl"`
+	Data []byte `json:"data"`
+}
+
+// Export writes every session of the store to w as JSON lines, with its
+// ID, remaining TTL and serialized payload, and returns the number of
+// sessions written. The first line is a header identifying the format.
+// The stream can be read back with Import, into another Redis server,
+// database or key prefix.
+//
+// Export walks the key prefix with SCAN, so sessions saved while it runs
+// may or may not be included. User indexes and metadata are not exported.
+//
+// Example:
+//
+//	f, _ := os.Create("sessions.jsonl")
+//	n, err := store.Export(ctx, f)
+func (s *RediStore) Export(ctx context.Context, w io.Writer) (int, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(exportHeader{Format: exportFormat, Version: exportVersion, Prefix: s.keyPrefix}); err != nil {
+		return 0, err
+	}
+	var (
+		cursor uint64
+		total  int
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		next, keys, err := s.scanPage(conn, cursor, s.scanCount)
+		if err != nil {
+			return total, err
+		}
+		for _, key := range keys {
+			if err := conn.Send("PTTL", key); err != nil {
+				return total, err
+			}
+			if err := conn.Send("GET", key); err != nil {
+				return total, err
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			return total, err
+		}
+		for _, key := range keys {
+			ttl, err := redis.Int64(conn.Receive())
+			if err != nil {
+				return total, err
+			}
+			data, err := redis.Bytes(conn.Receive())
+			if errors.Is(err, redis.ErrNil) || ttl == -2 {
+				continue // expired since SCAN
+			}
+			if err != nil {
+				return total, err
+			}
+			rec := exportRecord{ID: key[len(s.keyPrefix):], TTL: max(ttl, 0), Data: data}
+			if err := enc.Encode(rec); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if next == 0 {
+			return total, nil
+		}
+		cursor = next
+	}
+}
+
+// ImportOption configures a call to RediStore.Import.
+type ImportOption func(*importConfig)
+
+type importConfig struct {
+	serializer SessionSerializer
+}
+
+// WithImportSerializer sets the serializer the imported payloads were
+// written with. Each session is decoded with it and re-encoded with the
+// store's serializer, so that sessions can move to a new serializer on the
+// way in. By default payloads are written unchanged.
+func WithImportSerializer(serializer SessionSerializer) ImportOption {
+	return func(cfg *importConfig) {
+		cfg.serializer = serializer
+	}
+}
+
+// Import reads a stream written by Export and stores each session under
+// the store's key prefix, which may differ from the exporting store's,
+// with its remaining TTL. Existing sessions with the same ID are
+// overwritten. It returns the number of sessions imported; when a record
+// fails, the sessions before it have been imported.
+//
+// Example:
+//
+//	f, _ := os.Open("sessions.jsonl")
+//	n, err := newStore.Import(ctx, f, redistore.WithImportSerializer(redistore.GobSerializer{}))
+func (s *RediStore) Import(ctx context.Context, r io.Reader, opts ...ImportOption) (int, error) {
+	var cfg importConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	dec := json.NewDecoder(r)
+	var header exportHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("reading export header: %w", err)
+	}
+	if header.Format != exportFormat || header.Version != exportVersion {
+		return 0, fmt.Errorf("unsupported export format %q version %d", header.Format, header.Version)
+	}
+
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return 0, err
+	}
+	var (
+		total int
+		batch [][]interface{}
+	)
+	// flush writes the batch so that the count stays accurate when a later
+	// record fails.
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.writeImport(conn, batch); err != nil {
+			return err
+		}
+		total += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	fail := func(err error) (int, error) {
+		if ferr := flush(); ferr != nil {
+			return total, errors.Join(err, ferr)
+		}
+		return total, err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		var rec exportRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		n := total + len(batch) + 1
+		if err != nil {
+			return fail(fmt.Errorf("reading session %d: %w", n, err))
+		}
+		if rec.ID == "" {
+			return fail(fmt.Errorf("reading session %d: missing id", n))
+		}
+		data := rec.Data
+		if cfg.serializer != nil {
+			session := s.newStoredSession(rec.ID)
+			if err := cfg.serializer.Deserialize(data, session); err != nil {
+				return fail(fmt.Errorf("decoding session %s: %w", rec.ID, err))
+			}
+			if data, err = s.encode(session); err != nil {
+				return fail(fmt.Errorf("encoding session %s: %w", rec.ID, err))
+			}
+		}
+		args := []interface{}{s.sessionKey(rec.ID), data}
+		if rec.TTL > 0 {
+			args = append(args, "PX", rec.TTL)
+		}
+		batch = append(batch, args)
+		if len(batch) == s.scanCount {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// writeImport stores a batch of SET arguments in one pipeline and
+// invalidates any cached copies of the keys.
+func (s *RediStore) writeImport(conn redis.Conn, batch [][]interface{}) error {
+	keys := make([]string, len(batch))
+	for i, args := range batch {
+		if err := conn.Send("SET", args...); err != nil {
+			return err
+		}
+		keys[i] = args[0].(string)
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for range batch {
+		if _, err := conn.Receive(); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		s.cache.invalidate(key)
+	}
+	return s.publishInvalidation(conn, keys...)
+}
@@ -0,0 +1,107 @@
+package redistore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestExportImport(t *testing.T) {
+	addr := setup()
+	newStore := func(prefix string, serializer SessionSerializer) *RediStore {
+		store, err := NewStore(
+			[][]byte{[]byte(testHashKey)},
+			WithAddress("tcp", addr),
+			WithKeyPrefix(prefix),
+			WithSerializer(serializer),
+		)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return store
+	}
+	tag := newSessionID()[:8]
+	src := newStore("export_"+tag+"_", GobSerializer{})
+	dst := newStore("import_"+tag+"_", JSONSerializer{})
+	defer func() {
+		for _, store := range []*RediStore{src, dst} {
+			if err := store.Close(); err != nil {
+				fmt.Printf("Error closing store: %v\n", err)
+			}
+		}
+	}()
+
+	ids := make([]string, 3)
+	for i := range ids {
+		session := sessions.NewSession(src, "session-key")
+		session.Options = &sessions.Options{MaxAge: 600}
+		session.ID = newSessionID()
+		session.Values["n"] = fmt.Sprint(i)
+		if err := src.save(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = session.ID
+	}
+
+	ctx := context.Background()
+	var buf bytes.Buffer
+	n, err := src.Export(ctx, &buf)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 exported sessions, got %d", n)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("Expected a header and 3 lines, got %d lines", lines)
+	}
+
+	n, err = dst.Import(ctx, bytes.NewReader(buf.Bytes()), WithImportSerializer(GobSerializer{}))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 imported sessions, got %d", n)
+	}
+	for i, id := range ids {
+		session := dst.newStoredSession(id)
+		if ok, err := dst.load(context.Background(), session); err != nil || !ok {
+			t.Fatalf("Expected session %s under the new prefix: %v %v", id, ok, err)
+		}
+		if session.Values["n"] != fmt.Sprint(i) {
+			t.Errorf("Expected value %d, got %v", i, session.Values["n"])
+		}
+		ttl, err := dst.TTL(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ttl <= 590e9 || ttl > 600e9 {
+			t.Errorf("Expected the TTL to be preserved, got %v", ttl)
+		}
+	}
+
+	// Payloads are copied as-is without a source serializer.
+	plain := newStore("plain_"+tag+"_", GobSerializer{})
+	defer plain.Close()
+	if n, err := plain.Import(ctx, bytes.NewReader(buf.Bytes())); err != nil || n != 3 {
+		t.Errorf("Expected 3 sessions imported unchanged, got %d, %v", n, err)
+	}
+	session := plain.newStoredSession(ids[0])
+	if ok, err := plain.load(context.Background(), session); err != nil || !ok || session.Values["n"] != "0" {
+		t.Errorf("Expected gob payload to load, got %v %v %v", ok, err, session.Values)
+	}
+}
+
+func TestImport_Invalid(t *testing.T) {
+	store := &RediStore{}
+	if _, err := store.Import(context.Background(), strings.NewReader(`{"format":"other"}`)); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	if _, err := store.Import(context.Background(), strings.NewReader("")); err == nil {
+		t.Error("Expected error for missing header")
+	}
+}
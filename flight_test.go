@@ -0,0 +1,101 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroup_Coalesces(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("payload"), nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = g.do("key", fn)
+		}(i)
+	}
+	// Give the goroutines time to join the in-flight call.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected 1 call, got %d", got)
+	}
+	for i, b := range results {
+		if string(b) != "payload" {
+			t.Errorf("Result %d: expected payload, got %q", i, b)
+		}
+	}
+
+	// A finished call must not be reused.
+	if _, err := g.do("key", func() ([]byte, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected a new call to run its own function, got %d calls", got)
+	}
+}
+
+func TestConcurrentLoadsGetOwnValues(t *testing.T) {
+	addr := setup()
+	store := createTestStore(t, addr)
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session := getSession(t, store, req)
+	session.Values["user"] = "alice"
+	saveSession(t, req, rsp)
+	cookie := getCookies(t, rsp)[0]
+
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequestWithContext(
+				context.Background(), "GET", "http://localhost:8080/", nil)
+			req.Header.Add("Cookie", cookie)
+			s, err := store.New(req, "session-key")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if s.Values["user"] != "alice" {
+				errs <- fmt.Errorf("expected alice, got %v", s.Values["user"])
+				return
+			}
+			// Mutating one copy must not leak into the others.
+			s.Values["user"] = i
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// hashedIDPrefix marks the stored form of a session ID in hashed key mode.
+// It contains a character that none of the built-in ID generators produce.
+const hashedIDPrefix = "h:"
+
+// minKeyHashSecret is the minimum length of the WithHashedKeys secret.
+const minKeyHashSecret = 32
+
+// WithHashedKeys stores each session under keyPrefix + HMAC-SHA256(secret,
+// ID) rather than keyPrefix + ID, so that the IDs carried by cookies never
+// appear in Redis and a dump or SCAN of the server can't be used to forge
+// cookies. The secret must be at least 32 bytes and must be kept stable;
+// changing it orphans every session.
+//
+// In this mode the methods that work without a cookie, such as Sessions,
+// ListUserSessions, TTL, DeleteByID, Metadata, LoadMany and the admin
+// handler, identify sessions by their stored ID, "h:" followed by the hex
+// HMAC, which they both return and accept. Session IDs are hashed when
+// passed to them, so either form may be used.
+//
+// Example:
+//
+//	WithHashedKeys([]byte(os.Getenv("SESSION_KEY_SECRET")))
+func WithHashedKeys(secret []byte) Option {
+	return func(cfg *storeConfig) error {
+		if len(secret) < minKeyHashSecret {
+			return fmt.Errorf("key hash secret must be at least %d bytes, got %d", minKeyHashSecret, len(secret))
+		}
+		cfg.keyHashSecret = append([]byte(nil), secret...)
+		return nil
+	}
+}
+
+// storedID returns the form of id used in Redis keys and indexes: id
+// itself, or its HMAC in hashed key mode. IDs already in stored form are
+// returned unchanged.
+func (s *RediStore) storedID(id string) string {
+	if s.keyHashSecret == nil || s.isStoredID(id) {
+		return id
+	}
+	mac := hmac.New(sha256.New, s.keyHashSecret)
+	mac.Write([]byte(id))
+	return hashedIDPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// isStoredID reports whether id is a hashed stored ID.
+func (s *RediStore) isStoredID(id string) bool {
+	return s.keyHashSecret != nil && strings.HasPrefix(id, hashedIDPrefix)
+}
+
+// sessionKey returns the Redis key of the session with the given ID.
+func (s *RediStore) sessionKey(id string) string {
+	return s.keyPrefix + s.storedID(id)
+}
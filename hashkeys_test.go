@@ -0,0 +1,133 @@
+package redistore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+)
+
+func TestHashedKeys(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("hashed_"+newSessionID()[:8]+"_"),
+		WithHashedKeys(bytes.Repeat([]byte("k"), 32)),
+		WithMetadata(),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	conn := store.Pool.Get()
+	defer conn.Close()
+	uid := "user-" + newSessionID()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["name"] = "alice"
+	if err := store.BindUser(session, uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	id := session.ID
+	stored := store.storedID(id)
+	if !strings.HasPrefix(stored, hashedIDPrefix) || store.storedID(stored) != stored {
+		t.Fatalf("Unexpected stored ID %q", stored)
+	}
+
+	// The session ID never appears in Redis.
+	if exists, _ := conn.Do("EXISTS", store.keyPrefix+id); exists != int64(0) {
+		t.Error("Expected no key with the plain session ID")
+	}
+	if exists, _ := conn.Do("EXISTS", store.keyPrefix+stored); exists != int64(1) {
+		t.Error("Expected the session under its hashed key")
+	}
+
+	// Load through the cookie.
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", getCookies(t, rsp)[0])
+	loaded, err := store.New(req, "session-key")
+	if err != nil || loaded.IsNew || loaded.Values["name"] != "alice" {
+		t.Fatalf("Expected to load the session, got %v %v %v", err, loaded.IsNew, loaded.Values)
+	}
+
+	// Enumeration, indexes and metadata use the stored ID.
+	seq, errFn := store.Sessions(context.Background())
+	var listed []string
+	for sid := range seq {
+		listed = append(listed, sid)
+	}
+	if err := errFn(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(listed, []string{stored}) {
+		t.Errorf("Expected Sessions to yield %s, got %v", stored, listed)
+	}
+	if ids, _ := store.ListUserSessions(uid); !slices.Equal(ids, []string{stored}) {
+		t.Errorf("Expected the user index to hold %s, got %v", stored, ids)
+	}
+	for _, ref := range []string{id, stored} {
+		if _, err := store.Metadata(ref); err != nil {
+			t.Errorf("Metadata(%s) failed: %v", ref, err)
+		}
+		if _, err := store.TTL(ref); err != nil {
+			t.Errorf("TTL(%s) failed: %v", ref, err)
+		}
+	}
+
+	// A forged cookie carrying a stored ID is rejected.
+	forged, err := securecookie.EncodeMulti("session-key", stored, store.Codecs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: forged})
+	if s, err := store.New(req, "session-key"); err != ErrInvalidSessionID || !s.IsNew {
+		t.Errorf("Expected ErrInvalidSessionID, got %v", err)
+	}
+
+	// Regeneration moves the hashed key and the index entry.
+	if err := store.RegenerateID(req, NewRecorder(), loaded); err != nil {
+		t.Fatal(err)
+	}
+	newStored := store.storedID(loaded.ID)
+	if exists, _ := conn.Do("EXISTS", store.keyPrefix+stored); exists != int64(0) {
+		t.Error("Expected the old hashed key to be deleted")
+	}
+	if ids, _ := store.ListUserSessions(uid); !slices.Equal(ids, []string{newStored}) {
+		t.Errorf("Expected the user index to hold %s, got %v", newStored, ids)
+	}
+
+	if err := store.DeleteByID(newStored); err != nil {
+		t.Fatalf("DeleteByID failed: %v", err)
+	}
+	if ids, _ := store.ListUserSessions(uid); len(ids) != 0 {
+		t.Errorf("Expected no sessions after delete, got %v", ids)
+	}
+}
+
+func TestWithHashedKeys_Invalid(t *testing.T) {
+	if err := WithHashedKeys([]byte("short"))(defaultConfig()); err == nil {
+		t.Error("Expected error for short secret")
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSessionID is returned by New when the session ID in a cookie
+// doesn't match the format of the store's IDGenerator, or is a stored ID
+// in hashed key mode. The session is treated as new, with an empty ID.
+var ErrInvalidSessionID = errors.New("redistore: invalid session id")
+
+// IDGenerator generates session IDs. Generators must use a
+// cryptographically secure source of randomness.
+type IDGenerator interface {
+	// NewID returns a new session ID.
+	NewID() (string, error)
+
+	// Valid reports whether id has the format of the generated IDs. It is
+	// checked on IDs read from cookies before they are looked up in Redis.
+	Valid(id string) bool
+}
+
+// Alphabets for RandomIDGenerator.
+const (
+	AlphabetBase32    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	AlphabetBase62    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	AlphabetBase64URL = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	AlphabetHex       = "0123456789abcdef"
+)
+
+// minIDBits is the minimum entropy of the IDs of RandomIDGenerator.
+const minIDBits = 128
+
+// WithIDGenerator sets the generator of session IDs. IDs read from cookies
+// that don't match the generator's format are rejected with
+// ErrInvalidSessionID without touching Redis. By default IDs are 52
+// base32 characters, like those of RandomIDGenerator(52, AlphabetBase32),
+// and are not validated.
+//
+// Example:
+//
+//	WithIDGenerator(UUIDv7Generator())
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(cfg *storeConfig) error {
+		if gen == nil {
+			return errors.New("id generator cannot be nil")
+		}
+		// Catch misconfigured generators now rather than on first Save.
+		if _, err := gen.NewID(); err != nil {
+			return fmt.Errorf("invalid id generator: %w", err)
+		}
+		cfg.idGenerator = gen
+		return nil
+	}
+}
+
+// newID returns a new session ID from the store's generator.
+func (s *RediStore) newID() (string, error) {
+	if s.idGenerator == nil {
+		return newSessionID(), nil
+	}
+	return s.idGenerator.NewID()
+}
+
+// validID reports whether id, read from a cookie, may be looked up. A
+// stored ID is never accepted from a cookie, as it would give access to
+// the session to anyone who can read Redis.
+func (s *RediStore) validID(id string) bool {
+	if s.isStoredID(id) {
+		return false
+	}
+	return s.idGenerator == nil || s.idGenerator.Valid(id)
+}
+
+// randomGenerator generates IDs of a fixed length from an alphabet.
+type randomGenerator struct {
+	length   int
+	alphabet string
+}
+
+// RandomIDGenerator returns a generator of IDs of length characters drawn
+// uniformly from alphabet, such as AlphabetBase62. The alphabet must have
+// between 2 and 256 distinct characters, and the IDs at least 128 bits of
+// entropy.
+func RandomIDGenerator(length int, alphabet string) IDGenerator {
+	return randomGenerator{length: length, alphabet: alphabet}
+}
+
+func (g randomGenerator) check() error {
+	n := len(g.alphabet)
+	if n < 2 || n > 256 {
+		return fmt.Errorf("alphabet must have between 2 and 256 characters, got %d", n)
+	}
+	for i := 0; i < n; i++ {
+		if strings.IndexByte(g.alphabet[i+1:], g.alphabet[i]) >= 0 {
+			return fmt.Errorf("alphabet has duplicate character %q", g.alphabet[i])
+		}
+	}
+	if entropy := float64(g.length) * math.Log2(float64(n)); entropy < minIDBits {
+		return fmt.Errorf("ids of %d characters from %d have %.0f bits of entropy, need %d",
+			g.length, n, entropy, minIDBits)
+	}
+	return nil
+}
+
+func (g randomGenerator) NewID() (string, error) {
+	if err := g.check(); err != nil {
+		return "", err
+	}
+	n := len(g.alphabet)
+	// Mask bytes to the smallest power of two covering the alphabet and
+	// reject those past it, so that every character is equally likely.
+	mask := byte(1<<bits.Len(uint(n-1)) - 1)
+	id := make([]byte, 0, g.length)
+	buf := make([]byte, g.length*2)
+	for len(id) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if b &= mask; int(b) < n {
+				id = append(id, g.alphabet[b])
+				if len(id) == g.length {
+					break
+				}
+			}
+		}
+	}
+	return string(id), nil
+}
+
+func (g randomGenerator) Valid(id string) bool {
+	if len(id) != g.length {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(g.alphabet, id[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// uuidGenerator generates RFC 9562 UUIDs of version 4 or 7.
+type uuidGenerator struct {
+	version byte
+}
+
+// UUIDv4Generator returns a generator of random UUIDs, in their canonical
+// lowercase form.
+func UUIDv4Generator() IDGenerator {
+	return uuidGenerator{version: 4}
+}
+
+// UUIDv7Generator returns a generator of time-ordered UUIDs, whose first
+// 48 bits are the creation time in milliseconds and the rest random.
+func UUIDv7Generator() IDGenerator {
+	return uuidGenerator{version: 7}
+}
+
+func (g uuidGenerator) NewID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	if g.version == 7 {
+		var ts [8]byte
+		binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+		copy(u[:6], ts[2:])
+	}
+	u[6] = u[6]&0x0f | g.version<<4
+	u[8] = u[8]&0x3f | 0x80 // RFC 9562 variant
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:]), nil
+}
+
+func (g uuidGenerator) Valid(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+				return false
+			}
+		}
+	}
+	return id[14] == '0'+g.version && strings.IndexByte("89ab", id[19]) >= 0
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator generates ULIDs.
+type ulidGenerator struct{}
+
+// ULIDGenerator returns a generator of ULIDs: 26 Crockford base32
+// characters encoding a 48-bit millisecond timestamp and 80 random bits,
+// sortable by creation time.
+func ULIDGenerator() IDGenerator {
+	return ulidGenerator{}
+}
+
+func (ulidGenerator) NewID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ts[2:])
+
+	// Encode the 128 bits as 26 characters of 5 bits, the first holding
+	// only the top 3 bits.
+	hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:]), nil
+}
+
+func (ulidGenerator) Valid(id string) bool {
+	if len(id) != 26 || id[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(crockford, id[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
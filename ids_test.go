@@ -0,0 +1,128 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+)
+
+func TestIDGenerators(t *testing.T) {
+	tests := []struct {
+		name   string
+		gen    IDGenerator
+		format *regexp.Regexp
+	}{
+		{"base62", RandomIDGenerator(22, AlphabetBase62), regexp.MustCompile(`^[0-9A-Za-z]{22}$`)},
+		{"hex", RandomIDGenerator(32, AlphabetHex), regexp.MustCompile(`^[0-9a-f]{32}$`)},
+		{"uuidv4", UUIDv4Generator(), regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"uuidv7", UUIDv7Generator(), regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"ulid", ULIDGenerator(), regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			for i := 0; i < 100; i++ {
+				id, err := tt.gen.NewID()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !tt.format.MatchString(id) {
+					t.Fatalf("Unexpected format %q", id)
+				}
+				if !tt.gen.Valid(id) {
+					t.Fatalf("Expected %q to be valid", id)
+				}
+				if seen[id] {
+					t.Fatalf("Duplicate id %q", id)
+				}
+				seen[id] = true
+			}
+			for _, id := range []string{"", "../../etc", newSessionID()} {
+				if tt.gen.Valid(id) {
+					t.Errorf("Expected %q to be invalid", id)
+				}
+			}
+		})
+	}
+
+	// The default format is a random base32 ID of 52 characters.
+	if !RandomIDGenerator(52, AlphabetBase32).Valid(newSessionID()) {
+		t.Error("Expected default IDs to match RandomIDGenerator(52, AlphabetBase32)")
+	}
+}
+
+func TestUUIDv7_Ordered(t *testing.T) {
+	gen := UUIDv7Generator()
+	a, _ := gen.NewID()
+	b, _ := ULIDGenerator().NewID()
+	if a[:8] == "00000000" || b[:6] == "000000" {
+		t.Errorf("Expected a timestamp prefix, got %s and %s", a, b)
+	}
+}
+
+func TestWithIDGenerator_Invalid(t *testing.T) {
+	cfg := defaultConfig()
+	if err := WithIDGenerator(nil)(cfg); err == nil {
+		t.Error("Expected error for nil generator")
+	}
+	if err := WithIDGenerator(RandomIDGenerator(8, AlphabetHex))(cfg); err == nil {
+		t.Error("Expected error for low entropy")
+	}
+	if err := WithIDGenerator(RandomIDGenerator(64, "aa"))(cfg); err == nil {
+		t.Error("Expected error for duplicate characters")
+	}
+	if err := WithIDGenerator(RandomIDGenerator(64, "a"))(cfg); err == nil {
+		t.Error("Expected error for a single character alphabet")
+	}
+}
+
+func TestIDGenerator_RejectsInvalidCookie(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithIDGenerator(UUIDv4Generator()),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if !UUIDv4Generator().Valid(session.ID) {
+		t.Errorf("Expected a UUID, got %q", session.ID)
+	}
+
+	// A correctly signed cookie with an ID of the wrong format.
+	encoded, err := securecookie.EncodeMulti("session-key", newSessionID(), store.Codecs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	session, err = store.New(req, "session-key")
+	if err != ErrInvalidSessionID {
+		t.Errorf("Expected ErrInvalidSessionID, got %v", err)
+	}
+	if !session.IsNew || session.ID != "" {
+		t.Errorf("Expected a new session without ID, got %q", session.ID)
+	}
+}
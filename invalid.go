@@ -0,0 +1,93 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// InvalidCookiePolicy decides what New does with a cookie that fails to
+// decode, because it was tampered with, has expired or was encoded with a
+// key that was rotated away, or that holds an invalid session ID.
+type InvalidCookiePolicy int
+
+const (
+	// InvalidCookieError returns the error with a new session, as
+	// gorilla/sessions stores do. The registry of Get caches the error for
+	// the rest of the request.
+	InvalidCookieError InvalidCookiePolicy = iota
+
+	// InvalidCookieReset returns a new session and no error, and clears
+	// the cookie.
+	InvalidCookieReset
+
+	// InvalidCookieNotify behaves like InvalidCookieReset, and also calls
+	// the handler set with WithInvalidCookieHandler.
+	InvalidCookieNotify
+)
+
+// WithInvalidCookiePolicy sets what New does with invalid cookies. Default
+// is InvalidCookieError. Under InvalidCookieReset and InvalidCookieNotify
+// the cookie is cleared in the response if the handler is wrapped with
+// ReissueCookies, and is otherwise replaced when the new session is saved.
+//
+// Invalid cookies are counted under every policy, see InvalidCookies.
+//
+// Example:
+//
+//	WithInvalidCookiePolicy(InvalidCookieNotify),
+//	WithInvalidCookieHandler(func(r *http.Request, name string, err error) {
+//	    log.Printf("invalid %s cookie from %s: %v", name, r.RemoteAddr, err)
+//	}),
+func WithInvalidCookiePolicy(policy InvalidCookiePolicy) Option {
+	return func(cfg *storeConfig) error {
+		if policy < InvalidCookieError || policy > InvalidCookieNotify {
+			return fmt.Errorf("unknown invalid cookie policy %d", policy)
+		}
+		cfg.invalidCookiePolicy = policy
+		return nil
+	}
+}
+
+// WithInvalidCookieHandler sets the function called by New with the
+// session name and the decoding error of each invalid cookie, under
+// InvalidCookieNotify. It is called synchronously.
+func WithInvalidCookieHandler(fn func(r *http.Request, name string, err error)) Option {
+	return func(cfg *storeConfig) error {
+		if fn == nil {
+			return errors.New("invalid cookie handler cannot be nil")
+		}
+		cfg.onInvalidCookie = fn
+		return nil
+	}
+}
+
+// InvalidCookies returns the number of invalid cookies New has seen since
+// the store was created, to alert on floods of tampered cookies.
+func (s *RediStore) InvalidCookies() uint64 {
+	return s.invalidCookies.Load()
+}
+
+// invalidCookie counts an invalid cookie and applies the policy. It
+// returns the error for New to return, if any.
+func (s *RediStore) invalidCookie(r *http.Request, session *sessions.Session, err error) error {
+	s.invalidCookies.Add(1)
+	session.ID = ""
+	switch s.invalidCookiePolicy {
+	case InvalidCookieError:
+		return err
+	case InvalidCookieNotify:
+		s.onInvalidCookie(r, session.Name(), err)
+	}
+	// Cleared in the response unless the session is saved, see reissue.
+	if pending := pendingReissues(r); pending != nil {
+		pending.add(session)
+	}
+	return nil
+}
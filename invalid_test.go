@@ -0,0 +1,129 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestInvalidCookiePolicy(t *testing.T) {
+	addr := setup()
+	tampered := func() *http.Request {
+		req, _ := http.NewRequestWithContext(
+			context.Background(), "GET", "http://localhost:8080/", nil)
+		req.Header.Add("Cookie", "session-key=tampered")
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+		events  int
+	}{
+		{"error", nil, true, 0},
+		{"reset", []Option{WithInvalidCookiePolicy(InvalidCookieReset)}, false, 0},
+		{"notify", []Option{WithInvalidCookiePolicy(InvalidCookieNotify)}, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []error
+			opts := append([]Option{
+				WithAddress("tcp", addr),
+				WithInvalidCookieHandler(func(r *http.Request, name string, err error) {
+					if name != "session-key" {
+						t.Errorf("Expected session-key, got %s", name)
+					}
+					events = append(events, err)
+				}),
+			}, tt.opts...)
+			store, err := NewStore([][]byte{[]byte(testHashKey)}, opts...)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			defer func() {
+				if err := store.Close(); err != nil {
+					fmt.Printf("Error closing store: %v\n", err)
+				}
+			}()
+
+			session, err := store.New(tampered(), "session-key")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if session == nil || !session.IsNew || session.ID != "" {
+				t.Errorf("Expected a new session, got %+v", session)
+			}
+			if len(events) != tt.events {
+				t.Errorf("Expected %d events, got %d", tt.events, len(events))
+			}
+			if n := store.InvalidCookies(); n != 1 {
+				t.Errorf("Expected 1 invalid cookie counted, got %d", n)
+			}
+
+			// Under ReissueCookies, reset policies clear the cookie.
+			rsp := NewRecorder()
+			store.ReissueCookies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = store.Get(r, "session-key")
+				w.WriteHeader(http.StatusNoContent)
+			})).ServeHTTP(rsp, tampered())
+			cookies := rsp.Header()["Set-Cookie"]
+			if tt.wantErr && len(cookies) != 0 {
+				t.Errorf("Expected no cookie, got %v", cookies)
+			}
+			if !tt.wantErr && (len(cookies) != 1 || !strings.Contains(cookies[0], "Max-Age=0")) {
+				t.Errorf("Expected the cookie to be cleared, got %v", cookies)
+			}
+		})
+	}
+}
+
+func TestInvalidCookiePolicy_SavedSession(t *testing.T) {
+	store, err := NewStore([][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", setup()),
+		WithInvalidCookiePolicy(InvalidCookieReset),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", "session-key=tampered")
+	rsp := NewRecorder()
+	store.ReissueCookies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := store.Get(r, "session-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := session.Save(r, w); err != nil {
+			t.Fatal(err)
+		}
+	})).ServeHTTP(rsp, req)
+	if cookie := getCookies(t, rsp)[0]; strings.Contains(cookie, "Max-Age=0") {
+		t.Errorf("Expected the saved session's cookie only, got %s", cookie)
+	}
+}
+
+func TestWithInvalidCookiePolicy_Invalid(t *testing.T) {
+	if err := WithInvalidCookiePolicy(InvalidCookiePolicy(42))(defaultConfig()); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+	if err := WithInvalidCookieHandler(nil)(defaultConfig()); err == nil {
+		t.Error("Expected error for nil handler")
+	}
+	_, err := NewStore([][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", setup()),
+		WithInvalidCookiePolicy(InvalidCookieNotify),
+	)
+	if err == nil || !strings.Contains(err.Error(), "WithInvalidCookieHandler") {
+		t.Errorf("Expected InvalidCookieNotify to require a handler, got %v", err)
+	}
+}
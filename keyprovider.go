@@ -0,0 +1,291 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// Defaults for WithKeyRefresh and WithKeyOverlap.
+const (
+	defaultKeyRefresh = time.Minute
+	defaultKeyOverlap = 24 * time.Hour
+)
+
+// KeyProvider supplies the cookie key pairs of a store at runtime, in the
+// same layout as the keyPairs of NewStore: alternating hash and block
+// keys, the primary pair first.
+type KeyProvider interface {
+	Keys(ctx context.Context) ([][]byte, error)
+}
+
+// KeyNotifier may be implemented by a KeyProvider to signal that its keys
+// changed, so that the store refreshes them at once rather than at the
+// next poll. Closing the channel stops notifications; polling goes on.
+type KeyNotifier interface {
+	KeysChanged() <-chan struct{}
+}
+
+// KeyProviderFunc adapts a function to the KeyProvider interface.
+type KeyProviderFunc func(ctx context.Context) ([][]byte, error)
+
+// Keys calls f(ctx).
+func (f KeyProviderFunc) Keys(ctx context.Context) ([][]byte, error) {
+	return f(ctx)
+}
+
+// WithKeyProvider makes the store load its cookie keys from provider, and
+// poll it for changes, instead of using the keyPairs of NewStore, which
+// may then be nil. When the keys change, the codecs are swapped
+// atomically; pairs that were dropped keep decoding cookies for the
+// overlap window set with WithKeyOverlap, then are removed.
+//
+// Example:
+//
+//	store, err := NewStore(nil,
+//	    WithAddress("tcp", ":6379"),
+//	    WithKeyProvider(KeyProviderFunc(loadKeysFromVault)),
+//	)
+func WithKeyProvider(provider KeyProvider) Option {
+	return func(cfg *storeConfig) error {
+		if provider == nil {
+			return errors.New("key provider cannot be nil")
+		}
+		cfg.keyProvider = provider
+		return nil
+	}
+}
+
+// WithKeyRefresh sets how often the KeyProvider is polled. Default is one
+// minute.
+func WithKeyRefresh(interval time.Duration) Option {
+	return func(cfg *storeConfig) error {
+		if interval <= 0 {
+			return fmt.Errorf("key refresh interval must be positive, got %v", interval)
+		}
+		cfg.keyRefresh = interval
+		return nil
+	}
+}
+
+// WithKeyOverlap sets how long key pairs dropped by the KeyProvider keep
+// decoding cookies. Default is 24 hours.
+func WithKeyOverlap(d time.Duration) Option {
+	return func(cfg *storeConfig) error {
+		if d < 0 {
+			return fmt.Errorf("key overlap cannot be negative, got %v", d)
+		}
+		cfg.keyOverlap = d
+		return nil
+	}
+}
+
+// retiredPair is a key pair dropped by the provider and kept for decoding
+// until a deadline.
+type retiredPair struct {
+	pair  [][]byte
+	until time.Time
+}
+
+// keyring holds the codecs built from a KeyProvider and refreshes them in
+// the background.
+type keyring struct {
+	provider KeyProvider
+	refresh  time.Duration
+	overlap  time.Duration
+
+	codecs atomic.Pointer[[]securecookie.Codec]
+
+	mu      sync.Mutex // guards current and retired
+	current [][][]byte
+	retired []retiredPair
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newKeyring(provider KeyProvider, refresh, overlap time.Duration) *keyring {
+	return &keyring{
+		provider: provider,
+		refresh:  refresh,
+		overlap:  overlap,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// load returns the current codecs, primary first.
+func (k *keyring) load() []securecookie.Codec {
+	return *k.codecs.Load()
+}
+
+// splitPairs groups key pairs into hash and block keys.
+func splitPairs(keyPairs [][]byte) [][][]byte {
+	var pairs [][][]byte
+	for i := 0; i < len(keyPairs); i += 2 {
+		end := min(i+2, len(keyPairs))
+		pairs = append(pairs, keyPairs[i:end:end])
+	}
+	return pairs
+}
+
+func samePair(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPair(pairs [][][]byte, pair [][]byte) bool {
+	for _, p := range pairs {
+		if samePair(p, pair) {
+			return true
+		}
+	}
+	return false
+}
+
+// update fetches the keys from the provider, retires dropped pairs and
+// removes expired ones, and swaps the codecs if anything changed.
+func (k *keyring) update(ctx context.Context) error {
+	keyPairs, err := k.provider.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	if len(keyPairs) == 0 {
+		return errors.New("key provider returned no keys")
+	}
+	if err := validateKeyPairs(keyPairs); err != nil {
+		return err
+	}
+	pairs := splitPairs(keyPairs)
+	now := time.Now()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	changed := len(pairs) != len(k.current)
+	for i := range pairs {
+		if !changed && !samePair(pairs[i], k.current[i]) {
+			changed = true
+		}
+	}
+	var retired []retiredPair
+	for _, r := range k.retired {
+		// Pairs that came back and expired pairs are dropped.
+		if now.Before(r.until) && !containsPair(pairs, r.pair) {
+			retired = append(retired, r)
+		} else {
+			changed = true
+		}
+	}
+	for _, p := range k.current {
+		if !containsPair(pairs, p) && k.overlap > 0 {
+			retired = append(retired, retiredPair{pair: p, until: now.Add(k.overlap)})
+		}
+	}
+	if !changed && k.codecs.Load() != nil {
+		return nil
+	}
+	k.current, k.retired = pairs, retired
+	k.swap()
+	return nil
+}
+
+// swap builds and publishes the codecs. k.mu must be held.
+func (k *keyring) swap() {
+	var codecs []securecookie.Codec
+	for _, p := range k.current {
+		codecs = append(codecs, securecookie.CodecsFromPairs(p...)...)
+	}
+	for _, r := range k.retired {
+		codecs = append(codecs, securecookie.CodecsFromPairs(r.pair...)...)
+	}
+	withoutMaxAge(codecs)
+	k.codecs.Store(&codecs)
+}
+
+// run refreshes the keys until stop is closed.
+func (k *keyring) run() {
+	defer close(k.done)
+	ticker := time.NewTicker(k.refresh)
+	defer ticker.Stop()
+	var notify <-chan struct{}
+	if n, ok := k.provider.(KeyNotifier); ok {
+		notify = n.KeysChanged()
+	}
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+		case _, ok := <-notify:
+			if !ok {
+				// The provider stopped notifying; keep polling.
+				notify = nil
+				continue
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), k.refresh)
+		if err := k.update(ctx); err != nil {
+			fmt.Printf("redistore: error refreshing keys: %v\n", err)
+		}
+		cancel()
+	}
+}
+
+// startKeyring loads the keys from the provider and starts refreshing
+// them in the background.
+func (s *RediStore) startKeyring(k *keyring) error {
+	ctx, cancel := context.WithTimeout(context.Background(), k.refresh)
+	defer cancel()
+	if err := k.update(ctx); err != nil {
+		return err
+	}
+	s.keyring = k
+	go k.run()
+	return nil
+}
+
+// stopKeyring stops refreshing the keys.
+func (s *RediStore) stopKeyring() {
+	if s.keyring == nil {
+		return
+	}
+	select {
+	case <-s.keyring.stop:
+	default:
+		close(s.keyring.stop)
+	}
+	<-s.keyring.done
+}
+
+// codecs returns the codecs used to encode and decode cookies: those of
+// the KeyProvider if there is one, or Codecs.
+func (s *RediStore) codecs() []securecookie.Codec {
+	if s.keyring != nil {
+		return s.keyring.load()
+	}
+	return s.Codecs
+}
+
+// CurrentCodecs returns the codecs the store currently uses, primary
+// first. With a KeyProvider they change over time and Codecs only holds
+// those loaded by NewStore.
+func (s *RediStore) CurrentCodecs() []securecookie.Codec {
+	return s.codecs()
+}
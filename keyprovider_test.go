@@ -0,0 +1,192 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// rotatingKeys is a KeyProvider whose keys can be swapped by tests.
+type rotatingKeys struct {
+	mu      sync.Mutex
+	keys    [][]byte
+	changed chan struct{}
+}
+
+func (p *rotatingKeys) Keys(ctx context.Context) ([][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.keys, nil
+}
+
+func (p *rotatingKeys) KeysChanged() <-chan struct{} {
+	return p.changed
+}
+
+func (p *rotatingKeys) set(keys ...[]byte) {
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	p.changed <- struct{}{}
+}
+
+func TestKeyProvider(t *testing.T) {
+	addr := setup()
+	provider := &rotatingKeys{keys: [][]byte{[]byte("first-key-0123456789abcdef012345")}, changed: make(chan struct{})}
+	store, err := NewStore(nil,
+		WithAddress("tcp", addr),
+		WithKeyProvider(provider),
+		WithKeyOverlap(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	oldCookie := getCookies(t, rsp)[0]
+
+	provider.set([]byte("second-key-0123456789abcdef01234"))
+	deadline := time.Now().Add(2 * time.Second)
+	for len(store.CurrentCodecs()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the notification to swap the codecs")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Cookies issued with the dropped key still decode during the overlap,
+	// and new cookies use the new primary key.
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", oldCookie)
+	loaded, err := store.New(req, "session-key")
+	if err != nil || loaded.Values["name"] != "alice" {
+		t.Fatalf("Expected the old cookie to decode, got %v", err)
+	}
+	rsp = NewRecorder()
+	if err := loaded.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", getCookies(t, rsp)[0])
+	c, err := req.Cookie("session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id string
+	if err := securecookie.New([]byte("second-key-0123456789abcdef01234"), nil).Decode("session-key", c.Value, &id); err != nil || id != loaded.ID {
+		t.Errorf("Expected the new cookie to be encoded with the new key, got %v", err)
+	}
+}
+
+func TestKeyring_ClosedNotifier(t *testing.T) {
+	var calls atomic.Int64
+	provider := &closingNotifier{
+		KeyProviderFunc: func(ctx context.Context) ([][]byte, error) {
+			calls.Add(1)
+			return [][]byte{[]byte("a-hash-key-0123456789abcdef01234")}, nil
+		},
+		changed: make(chan struct{}),
+	}
+	close(provider.changed)
+	k := newKeyring(provider, time.Hour, 0)
+	go k.run()
+	time.Sleep(20 * time.Millisecond)
+	close(k.stop)
+	<-k.done
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Expected no refresh after the notifier closed, got %d", n)
+	}
+}
+
+// closingNotifier is a KeyNotifier whose channel tests can close.
+type closingNotifier struct {
+	KeyProviderFunc
+	changed chan struct{}
+}
+
+func (p *closingNotifier) KeysChanged() <-chan struct{} {
+	return p.changed
+}
+
+func TestKeyring_Overlap(t *testing.T) {
+	provider := &rotatingKeys{keys: [][]byte{[]byte("a-hash-key-0123456789abcdef01234"), []byte("a-block-key-16by")}}
+	k := newKeyring(provider, time.Minute, 20*time.Millisecond)
+	ctx := context.Background()
+	if err := k.update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first := k.load()
+	if len(first) != 1 {
+		t.Fatalf("Expected 1 codec, got %d", len(first))
+	}
+	// Unchanged keys keep the same codecs.
+	if err := k.update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if &k.load()[0] != &first[0] {
+		t.Error("Expected codecs not to be rebuilt when keys are unchanged")
+	}
+
+	provider.keys = [][]byte{[]byte("b-hash-key-0123456789abcdef01234"), nil, []byte("a-hash-key-0123456789abcdef01234"), []byte("a-block-key-16by")}
+	if err := k.update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(k.load()); n != 2 {
+		t.Errorf("Expected 2 codecs for the provided pairs, got %d", n)
+	}
+	provider.keys = [][]byte{[]byte("b-hash-key-0123456789abcdef01234"), nil}
+	if err := k.update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(k.load()); n != 2 {
+		t.Errorf("Expected the dropped pair to be kept, got %d codecs", n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := k.update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(k.load()); n != 1 {
+		t.Errorf("Expected the dropped pair to be removed after the overlap, got %d codecs", n)
+	}
+
+	provider.keys = nil
+	if err := k.update(ctx); err == nil {
+		t.Error("Expected error for empty keys")
+	}
+}
+
+func TestWithKeyProvider_Invalid(t *testing.T) {
+	cfg := defaultConfig()
+	if err := WithKeyProvider(nil)(cfg); err == nil {
+		t.Error("Expected error for nil provider")
+	}
+	if err := WithKeyRefresh(0)(cfg); err == nil {
+		t.Error("Expected error for zero refresh interval")
+	}
+	if err := WithKeyOverlap(-time.Second)(cfg); err == nil {
+		t.Error("Expected error for negative overlap")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Key sizes accepted by NewStore and produced by KeysFromSecret.
+const (
+	minHashKeyLen      = 32
+	derivedHashKeyLen  = 64
+	derivedBlockKeyLen = 32
+)
+
+// validateKeyPairs checks the sizes of cookie keys: hash keys must have at
+// least 32 bytes and block keys must be nil or 16, 24 or 32 bytes, for
+// AES-128, AES-192 or AES-256.
+func validateKeyPairs(keyPairs [][]byte) error {
+	for i, key := range keyPairs {
+		pair := i/2 + 1
+		if i%2 == 0 {
+			if len(key) < minHashKeyLen {
+				return fmt.Errorf("hash key of pair %d must be at least %d bytes, got %d",
+					pair, minHashKeyLen, len(key))
+			}
+			continue
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			if key != nil {
+				return fmt.Errorf("block key of pair %d must be 16, 24 or 32 bytes, got %d",
+					pair, len(key))
+			}
+		}
+	}
+	return nil
+}
+
+// KeysFromSecret derives a key pair from a master secret with HKDF-SHA256
+// (RFC 5869): a 64-byte hash key and a 32-byte block key for AES-256. The
+// info string separates the keys of different uses of the same secret,
+// such as "sessions" and "csrf". The master secret should be random and at
+// least 32 bytes long.
+//
+// Example:
+//
+//	// Rotation: the pair derived from the new secret comes first.
+//	store, err := NewStore(
+//	    append(
+//	        KeysFromSecret(newSecret, "sessions"),
+//	        KeysFromSecret(oldSecret, "sessions")...,
+//	    ),
+//	    WithAddress("tcp", ":6379"),
+//	)
+func KeysFromSecret(master []byte, info string) [][]byte {
+	okm := hkdf(master, nil, []byte(info), derivedHashKeyLen+derivedBlockKeyLen)
+	return [][]byte{okm[:derivedHashKeyLen:derivedHashKeyLen], okm[derivedHashKeyLen:]}
+}
+
+// hkdf implements HKDF-SHA256 extract-and-expand. length must not exceed
+// 255 times the hash size.
+func hkdf(secret, salt, info []byte, length int) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	okm := make([]byte, 0, length+sha256.Size)
+	var prev []byte
+	for counter := byte(1); len(okm) < length; counter++ {
+		expand.Reset()
+		expand.Write(prev)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		prev = expand.Sum(nil)
+		okm = append(okm, prev...)
+	}
+	return okm[:length:length]
+}
@@ -0,0 +1,70 @@
+package redistore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestValidateKeyPairs(t *testing.T) {
+	hashKey := []byte(testHashKey)
+	tests := []struct {
+		name     string
+		keyPairs [][]byte
+		err      string
+	}{
+		{"hash key only", Keys(hashKey), ""},
+		{"AES-128", Keys(hashKey, make([]byte, 16)), ""},
+		{"AES-192", Keys(hashKey, make([]byte, 24)), ""},
+		{"AES-256", Keys(hashKey, make([]byte, 32)), ""},
+		{"nil block key", Keys(hashKey, nil, hashKey, make([]byte, 32)), ""},
+		{"short hash key", KeysFromStrings("secret-key"), "hash key of pair 1"},
+		{"empty block key", Keys(hashKey, []byte{}), "block key of pair 1"},
+		{"bad block key", KeysFromStrings(testHashKey, "encryption"), "block key of pair 1"},
+		{"second pair", Keys(hashKey, nil, []byte("old-key")), "hash key of pair 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKeyPairs(tt.keyPairs)
+			if tt.err == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+
+	if _, err := NewStore(KeysFromStrings("secret-key"), WithAddress("tcp", setup())); err == nil {
+		t.Error("Expected NewStore to reject a short hash key")
+	}
+}
+
+// TestHKDF checks the first test vector of RFC 5869.
+func TestHKDF(t *testing.T) {
+	ikm := bytes.Repeat([]byte{0x0b}, 22)
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	want := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+	if got := hex.EncodeToString(hkdf(ikm, salt, info, 42)); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestKeysFromSecret(t *testing.T) {
+	master := []byte("master-secret-of-the-application")
+	keys := KeysFromSecret(master, "sessions")
+	if len(keys) != 2 || len(keys[0]) != 64 || len(keys[1]) != 32 {
+		t.Fatalf("Expected a 64-byte hash key and a 32-byte block key, got %d keys", len(keys))
+	}
+	if err := validateKeyPairs(keys); err != nil {
+		t.Errorf("Expected derived keys to be valid, got %v", err)
+	}
+	if again := KeysFromSecret(master, "sessions"); !bytes.Equal(again[0], keys[0]) || !bytes.Equal(again[1], keys[1]) {
+		t.Error("Expected derivation to be deterministic")
+	}
+	if other := KeysFromSecret(master, "csrf"); bytes.Equal(other[0], keys[0]) {
+		t.Error("Expected different info to derive different keys")
+	}
+}
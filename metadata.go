@@ -0,0 +1,197 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+// ErrSessionNotFound is returned when a session doesn't exist in Redis,
+// either because it was never saved, was deleted or has expired.
+var ErrSessionNotFound = errors.New("redistore: session not found")
+
+// Metadata describes a session without its payload.
+//
+// Fields:
+//
+//	Created: When the session was first saved.
+//	LastSeen: When the session was last loaded or saved.
+//	RemoteAddr: Host of the client that last used the session.
+//	UserAgent: User-Agent of the client that last used the session.
+//	CodecIndex: Index in Codecs of the codec that last decoded the cookie,
+//	  or -1 if the cookie has not been read back yet.
+//	PrimaryCodec: Index in Codecs of the codec that last encoded the cookie.
+type Metadata struct {
+	Created      time.Time
+	LastSeen     time.Time
+	RemoteAddr   string
+	UserAgent    string
+	CodecIndex   int
+	PrimaryCodec int
+}
+
+// WithMetadata enables a metadata record kept next to each session, with
+// its creation and last access times, the client address and user agent,
+// and the codecs used for its cookie. The record is updated by New and
+// Save and read with Metadata. Default is disabled, since it adds a write
+// to every request that loads a session.
+//
+// Sessions of a profile with its own key prefix, see WithProfileKeyPrefix,
+// have no metadata record, since Metadata only looks up sessions under
+// the store's key prefix.
+func WithMetadata() Option {
+	return func(cfg *storeConfig) error {
+		cfg.metadata = true
+		return nil
+	}
+}
+
+// touchMetadataScript updates the metadata of a session, provided the
+// session exists, and makes it expire with the session.
+//
+// KEYS[1] - session key
+// KEYS[2] - metadata key
+// ARGV[1] - current unix time in milliseconds
+// ARGV[2] - remote address
+// ARGV[3] - user agent
+// ARGV[4] - index of the codec that decoded the cookie, -1 to leave as is
+// ARGV[5] - index of the codec that encoded the cookie, -1 to leave as is
+var touchMetadataScript = redis.NewScript(2, `
+local pttl = redis.call('PTTL', KEYS[1])
+if pttl < 0 then
+	return 0
+end
+redis.call('HSETNX', KEYS[2], 'created', ARGV[1])
+redis.call('HSETNX', KEYS[2], 'codec', -1)
+redis.call('HSET', KEYS[2], 'last_seen', ARGV[1], 'remote_addr', ARGV[2], 'user_agent', ARGV[3])
+if ARGV[4] ~= '-1' then
+	redis.call('HSET', KEYS[2], 'codec', ARGV[4])
+end
+if ARGV[5] ~= '-1' then
+	redis.call('HSET', KEYS[2], 'primary_codec', ARGV[5])
+end
+redis.call('PEXPIRE', KEYS[2], pttl)
+return 1
+`)
+
+// metadataKeyPrefix is prepended to session keys to get the keys of their
+// metadata records.
+const metadataKeyPrefix = "redistore:meta:"
+
+// metadataKey returns the Redis key of the metadata record of a session.
+// It deliberately doesn't start with keyPrefix so that scanning the key
+// prefix only yields sessions.
+func (s *RediStore) metadataKey(id string) string {
+	return metadataKeyPrefix + s.sessionKey(id)
+}
+
+// namedMetadataKey returns the Redis key of the metadata record of the
+// session with the given name and ID.
+func (s *RediStore) namedMetadataKey(name, id string) string {
+	return metadataKeyPrefix + s.namedSessionKey(name, id)
+}
+
+// remoteHost returns the host part of r.RemoteAddr.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// touchMetadata records an access to the session. It is a no-op unless
+// WithMetadata is enabled, and for sessions stored under a profile's own
+// key prefix.
+func (s *RediStore) touchMetadata(r *http.Request, session *sessions.Session, decoded, encoded int) error {
+	if !s.metadata || s.profile(session.Name()).keyPrefix != s.keyPrefix {
+		return nil
+	}
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	_, err := touchMetadataScript.Do(conn,
+		s.sessionKey(session.ID), s.metadataKey(session.ID),
+		time.Now().UnixMilli(), remoteHost(r), r.UserAgent(), decoded, encoded)
+	return err
+}
+
+// Metadata returns the metadata record of the session with the given ID,
+// without reading its payload. It returns ErrSessionNotFound if the
+// session doesn't exist, or has no metadata because it was saved before
+// WithMetadata was enabled.
+func (s *RediStore) Metadata(id string) (*Metadata, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+	if err := conn.Send("EXISTS", s.sessionKey(id)); err != nil {
+		return nil, err
+	}
+	if err := conn.Send("HGETALL", s.metadataKey(id)); err != nil {
+		return nil, err
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	exists, err := redis.Bool(conn.Receive())
+	if err != nil {
+		return nil, err
+	}
+	fields, err := redis.StringMap(conn.Receive())
+	if err != nil {
+		return nil, err
+	}
+	// Metadata outlives sessions deleted without going through this store
+	// until it expires, so only trust it if the session exists.
+	if !exists || len(fields) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return parseMetadata(fields)
+}
+
+// parseMetadata converts a metadata hash into a Metadata.
+func parseMetadata(fields map[string]string) (*Metadata, error) {
+	md := &Metadata{
+		RemoteAddr: fields["remote_addr"],
+		UserAgent:  fields["user_agent"],
+		CodecIndex: -1,
+	}
+	for name, dst := range map[string]*time.Time{"created": &md.Created, "last_seen": &md.LastSeen} {
+		if v, ok := fields[name]; ok {
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata field %s: %w", name, err)
+			}
+			*dst = time.UnixMilli(ms)
+		}
+	}
+	for name, dst := range map[string]*int{"codec": &md.CodecIndex, "primary_codec": &md.PrimaryCodec} {
+		if v, ok := fields[name]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata field %s: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+	return md, nil
+}
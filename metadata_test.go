@@ -0,0 +1,147 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestSessionMetadata(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		KeysFromStrings("new-key-0123456789abcdef01234567", "new-enc-key-16by", "old-key-0123456789abcdef01234567", "old-enc-key-16by"),
+		WithAddress("tcp", addr),
+		WithMetadata(),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	before := time.Now().Add(-time.Second)
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "first-agent")
+	rsp := NewRecorder()
+	session := getSession(t, store, req)
+	session.Values["user"] = "alice"
+	saveSession(t, req, rsp)
+	_ = getCookies(t, rsp)
+
+	md, err := store.Metadata(session.ID)
+	if err != nil {
+		t.Fatalf("Metadata failed: %v", err)
+	}
+	if md.Created.Before(before) || md.LastSeen.Before(md.Created) {
+		t.Errorf("Unexpected timestamps: %+v", md)
+	}
+	if md.RemoteAddr != "192.0.2.1" || md.UserAgent != "first-agent" {
+		t.Errorf("Unexpected client: %+v", md)
+	}
+	if md.CodecIndex != -1 || md.PrimaryCodec != 0 {
+		t.Errorf("Unexpected codecs: %+v", md)
+	}
+
+	// A cookie issued with the old key pair is decoded by the second codec.
+	old := &RediStore{Codecs: store.Codecs[1:]}
+	encoded, _, err := old.encodeCookie("session-key", session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.RemoteAddr = "192.0.2.2:4321"
+	req.Header.Set("User-Agent", "second-agent")
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: encoded})
+	if _, err := store.New(req, "session-key"); err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	md2, err := store.Metadata(session.ID)
+	if err != nil {
+		t.Fatalf("Metadata failed: %v", err)
+	}
+	if !md2.Created.Equal(md.Created) {
+		t.Errorf("Created changed from %v to %v", md.Created, md2.Created)
+	}
+	if md2.RemoteAddr != "192.0.2.2" || md2.UserAgent != "second-agent" || md2.CodecIndex != 1 {
+		t.Errorf("Unexpected metadata after load: %+v", md2)
+	}
+
+	if err := store.delete(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Metadata(session.ID); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionMetadata_ProfileKeyPrefix(t *testing.T) {
+	addr := setup()
+	prefix := "cart_" + newSessionID()[:8] + "_"
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithMetadata(),
+		WithSessionProfile("cart", WithProfileKeyPrefix(prefix)),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.Header.Set("User-Agent", "session-agent")
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A session of the profile with the same ID has no metadata of its
+	// own, and leaves that of the store's session alone.
+	req.Header.Set("User-Agent", "cart-agent")
+	cart, err := store.New(req, "cart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart.ID = session.ID
+	if err := cart.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	conn := store.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if n, err := redis.Int(conn.Do("EXISTS", store.namedMetadataKey("cart", cart.ID))); err != nil || n != 0 {
+		t.Errorf("Expected no metadata for the profile's session, got %d (%v)", n, err)
+	}
+	cart.Options.MaxAge = -1
+	if err := cart.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	md, err := store.Metadata(session.ID)
+	if err != nil {
+		t.Fatalf("Metadata failed: %v", err)
+	}
+	if md.UserAgent != "session-agent" {
+		t.Errorf("Expected the store's session metadata, got %+v", md)
+	}
+}
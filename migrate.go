@@ -0,0 +1,274 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// migrateRetries is the number of times a session changed by a concurrent
+// save is read again before it is left for a later run.
+const migrateRetries = 3
+
+// migrateScript copies a session to its new key, re-encoded, if it hasn't
+// changed since it was read, keeping its remaining TTL.
+//
+// The script returns "ok", "changed" if the source no longer holds the
+// payload that was decoded, or "exists" if the destination exists and
+// overwriting is disabled.
+//
+// KEYS[1] - source key
+// KEYS[2] - destination key
+// ARGV[1] - payload read from the source
+// ARGV[2] - re-encoded payload
+// ARGV[3] - 1 to overwrite an existing destination
+// ARGV[4] - 1 to delete the source
+var migrateScript = redis.NewScript(2, `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 'changed'
+end
+local same = KEYS[1] == KEYS[2]
+if not same and ARGV[3] ~= '1' and redis.call('EXISTS', KEYS[2]) == 1 then
+	return 'exists'
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('SET', KEYS[2], ARGV[2], 'PX', ttl)
+else
+	redis.call('SET', KEYS[2], ARGV[2])
+end
+if not same and ARGV[4] == '1' then
+	redis.call('DEL', KEYS[1])
+end
+return 'ok'
+`)
+
+// MigrationResult reports the progress of Migrate, including the sessions
+// migrated by earlier runs resumed from the same checkpoint.
+type MigrationResult struct {
+	// Migrated is the number of sessions written under the new prefix.
+	Migrated int
+
+	// Skipped is the number of sessions left alone, because the
+	// destination already existed or they kept changing while migrated.
+	Skipped int
+
+	// Failed is the number of sessions that couldn't be decoded or
+	// re-encoded. Their errors are returned by Migrate.
+	Failed int
+}
+
+// MigrateOption configures a call to RediStore.Migrate.
+type MigrateOption func(*migrateConfig)
+
+type migrateConfig struct {
+	checkpoint   string
+	overwrite    bool
+	deleteSource bool
+}
+
+// WithMigrationCheckpoint sets the Redis key where Migrate records its
+// progress. Default is "redistore:migrate:" followed by the old and new
+// key prefixes.
+func WithMigrationCheckpoint(key string) MigrateOption {
+	return func(cfg *migrateConfig) {
+		cfg.checkpoint = key
+	}
+}
+
+// WithMigrationOverwrite makes Migrate overwrite sessions that already
+// exist under the new prefix. By default they are kept, since they were
+// saved by a process already using the new configuration.
+func WithMigrationOverwrite() MigrateOption {
+	return func(cfg *migrateConfig) {
+		cfg.overwrite = true
+	}
+}
+
+// WithMigrationDeleteSource makes Migrate delete each session from the old
+// prefix once it has been copied. By default the old keys are left to
+// expire, so that processes still using the old configuration keep
+// working during a rolling deploy.
+func WithMigrationDeleteSource() MigrateOption {
+	return func(cfg *migrateConfig) {
+		cfg.deleteSource = true
+	}
+}
+
+// Migrate copies the sessions stored under fromPrefix, serialized with
+// fromSerializer, to the store's own key prefix and serializer, keeping
+// their remaining TTL. Use it to change WithKeyPrefix or WithSerializer in
+// a rolling deploy; fromPrefix may equal the store's prefix to re-encode
+// sessions in place.
+//
+// Each session is copied atomically, and only if it hasn't changed since
+// it was read. Progress is checkpointed in Redis after every SCAN page, so
+// an interrupted migration resumes where it stopped when Migrate is called
+// again with the same arguments. The checkpoint is removed once the walk
+// completes.
+//
+// Sessions that fail to decode are counted and skipped, and their errors
+// are joined into the returned error. User indexes and metadata are not
+// migrated; sessions are re-indexed the next time they are saved.
+//
+// Example:
+//
+//	// The store is configured with the new prefix and serializer.
+//	result, err := store.Migrate(ctx, "session_", redistore.GobSerializer{})
+func (s *RediStore) Migrate(ctx context.Context, fromPrefix string, fromSerializer SessionSerializer, opts ...MigrateOption) (*MigrationResult, error) {
+	if fromSerializer == nil {
+		return nil, errors.New("serializer cannot be nil")
+	}
+	if fromPrefix == "" {
+		return nil, errors.New("key prefix cannot be empty")
+	}
+	cfg := migrateConfig{checkpoint: "redistore:migrate:" + fromPrefix + ":" + s.keyPrefix}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+
+	var checkpoint struct {
+		Cursor   uint64 `redis:"cursor"`
+		Migrated int    `redis:"migrated"`
+		Skipped  int    `redis:"skipped"`
+		Failed   int    `redis:"failed"`
+	}
+	reply, err := redis.Values(conn.Do("HGETALL", cfg.checkpoint))
+	if err != nil {
+		return nil, err
+	}
+	if err := redis.ScanStruct(reply, &checkpoint); err != nil {
+		return nil, fmt.Errorf("reading migration checkpoint: %w", err)
+	}
+	result := &MigrationResult{
+		Migrated: checkpoint.Migrated,
+		Skipped:  checkpoint.Skipped,
+		Failed:   checkpoint.Failed,
+	}
+
+	// A new prefix nested under the old one is matched by the walk too.
+	nested := s.keyPrefix != fromPrefix && strings.HasPrefix(s.keyPrefix, fromPrefix)
+	pattern := matchPattern(fromPrefix)
+	cursor := checkpoint.Cursor
+	var errs []error
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, errors.Join(append(errs, err)...)
+		}
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", s.scanCount))
+		if err != nil {
+			return result, errors.Join(append(errs, err)...)
+		}
+		var keys []string
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return result, errors.Join(append(errs, err)...)
+		}
+		if nested {
+			keys = slices.DeleteFunc(keys, func(key string) bool {
+				return strings.HasPrefix(key, s.keyPrefix)
+			})
+		}
+		for _, key := range keys {
+			err := s.migrateKey(conn, key, key[len(fromPrefix):], fromSerializer, &cfg, result)
+			if err == nil {
+				continue
+			}
+			var rerr redis.Error
+			if errors.As(err, &rerr) || errors.Is(err, errMigrateDecode) {
+				errs = append(errs, err)
+				continue
+			}
+			return result, errors.Join(append(errs, err)...)
+		}
+		if cursor == 0 {
+			if _, err := conn.Do("DEL", cfg.checkpoint); err != nil {
+				errs = append(errs, err)
+			}
+			return result, errors.Join(errs...)
+		}
+		if _, err := conn.Do("HSET", cfg.checkpoint,
+			"cursor", cursor,
+			"migrated", result.Migrated,
+			"skipped", result.Skipped,
+			"failed", result.Failed,
+		); err != nil {
+			return result, errors.Join(append(errs, err)...)
+		}
+	}
+}
+
+// errMigrateDecode marks per-session errors that don't stop a migration.
+var errMigrateDecode = errors.New("migrating session")
+
+// migrateKey copies a single session, retrying if it changes concurrently.
+func (s *RediStore) migrateKey(conn redis.Conn, key, id string, from SessionSerializer, cfg *migrateConfig, result *MigrationResult) error {
+	for range migrateRetries {
+		data, err := redis.Bytes(conn.Do("GET", key))
+		if errors.Is(err, redis.ErrNil) {
+			return nil // expired since SCAN
+		}
+		if err != nil {
+			return err
+		}
+		session := s.newStoredSession(id)
+		if err := from.Deserialize(data, session); err != nil {
+			// SCAN may return a key twice, so a session re-encoded in
+			// place may come up again in the new format.
+			if key == s.sessionKey(id) && s.serializer.Deserialize(data, s.newStoredSession(id)) == nil {
+				result.Skipped++
+				return nil
+			}
+			result.Failed++
+			return fmt.Errorf("%w %s: %w", errMigrateDecode, id, err)
+		}
+		b, err := s.encode(session)
+		if err != nil {
+			result.Failed++
+			return fmt.Errorf("%w %s: %w", errMigrateDecode, id, err)
+		}
+		dst := s.sessionKey(id)
+		status, err := redis.String(migrateScript.Do(conn, key, dst, data, b,
+			boolArg(cfg.overwrite), boolArg(cfg.deleteSource)))
+		if err != nil {
+			return err
+		}
+		switch status {
+		case "ok":
+			result.Migrated++
+			s.cache.invalidate(dst)
+			return s.publishInvalidation(conn, dst)
+		case "exists":
+			result.Skipped++
+			return nil
+		}
+		// The session was saved since it was read; read it again.
+	}
+	result.Skipped++
+	return nil
+}
+
+// boolArg encodes a flag as a script argument.
+func boolArg(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
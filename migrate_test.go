@@ -0,0 +1,123 @@
+package redistore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestMigrate(t *testing.T) {
+	addr := setup()
+	tag := newSessionID()[:8]
+	newStore := func(prefix string, serializer SessionSerializer) *RediStore {
+		store, err := NewStore(
+			[][]byte{[]byte(testHashKey)},
+			WithAddress("tcp", addr),
+			WithKeyPrefix(prefix+tag+"_"),
+			WithSerializer(serializer),
+		)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return store
+	}
+	old := newStore("migold_", GobSerializer{})
+	store := newStore("mignew_", JSONSerializer{})
+	defer func() {
+		for _, s := range []*RediStore{old, store} {
+			if err := s.Close(); err != nil {
+				fmt.Printf("Error closing store: %v\n", err)
+			}
+		}
+	}()
+
+	save := func(s *RediStore, id, value string) {
+		session := sessions.NewSession(s, "session-key")
+		session.Options = &sessions.Options{MaxAge: 600}
+		session.ID = id
+		session.Values["v"] = value
+		if err := s.save(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := []string{newSessionID(), newSessionID(), newSessionID()}
+	for _, id := range ids {
+		save(old, id, "old")
+	}
+	// A session already saved under the new prefix is kept.
+	save(store, ids[2], "new")
+	// A payload the old serializer can't decode is reported.
+	conn := store.Pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("SETEX", old.keyPrefix+"garbage", 600, "not gob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Counts from an interrupted run are carried over.
+	checkpoint := "redistore:migrate:" + old.keyPrefix + ":" + store.keyPrefix
+	if _, err := conn.Do("HSET", checkpoint, "migrated", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	result, err := store.Migrate(ctx, old.keyPrefix, GobSerializer{})
+	if !errors.Is(err, errMigrateDecode) {
+		t.Errorf("Expected a decode error, got %v", err)
+	}
+	if *result != (MigrationResult{Migrated: 12, Skipped: 1, Failed: 1}) {
+		t.Errorf("Unexpected result %+v", *result)
+	}
+	if exists, _ := conn.Do("EXISTS", checkpoint); exists != int64(0) {
+		t.Error("Expected the checkpoint to be removed")
+	}
+	for i, id := range ids {
+		session := store.newStoredSession(id)
+		if ok, err := store.load(context.Background(), session); err != nil || !ok {
+			t.Fatalf("Expected migrated session %s: %v %v", id, ok, err)
+		}
+		want := "old"
+		if i == 2 {
+			want = "new"
+		}
+		if session.Values["v"] != want {
+			t.Errorf("Expected %q, got %v", want, session.Values["v"])
+		}
+		if ttl, err := store.TTL(id); err != nil || ttl <= 590e9 {
+			t.Errorf("Expected the TTL to be kept, got %v %v", ttl, err)
+		}
+		if exists, _ := conn.Do("EXISTS", old.keyPrefix+id); exists != int64(1) {
+			t.Error("Expected the old session to be kept")
+		}
+	}
+
+	// Re-encode in place, deleting nothing since the keys are the same.
+	if _, err := conn.Do("DEL", old.keyPrefix+"garbage"); err != nil {
+		t.Fatal(err)
+	}
+	inPlace := newStore("migold_", JSONSerializer{})
+	defer inPlace.Close()
+	result, err = inPlace.Migrate(ctx, old.keyPrefix, GobSerializer{}, WithMigrationDeleteSource())
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.Migrated != 3 {
+		t.Errorf("Expected 3 sessions re-encoded, got %+v", *result)
+	}
+	session := inPlace.newStoredSession(ids[0])
+	if ok, err := inPlace.load(context.Background(), session); err != nil || !ok || session.Values["v"] != "old" {
+		t.Errorf("Expected session re-encoded as JSON, got %v %v %v", ok, err, session.Values)
+	}
+}
+
+func TestMigrate_Invalid(t *testing.T) {
+	store := &RediStore{}
+	if _, err := store.Migrate(context.Background(), "", GobSerializer{}); err == nil {
+		t.Error("Expected error for empty prefix")
+	}
+	if _, err := store.Migrate(context.Background(), "old_", nil); err == nil {
+		t.Error("Expected error for nil serializer")
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"errors"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Operation is a store operation reported to an Observer.
+type Operation string
+
+// Operations reported to an Observer.
+const (
+	OpLoad   Operation = "load"
+	OpSave   Operation = "save"
+	OpDelete Operation = "delete"
+)
+
+// Outcome tells where an operation was served from.
+type Outcome string
+
+// Outcomes reported to an Observer.
+const (
+	// OutcomeCacheHit is a load served by the local cache.
+	OutcomeCacheHit Outcome = "cache_hit"
+
+	// OutcomeHit is a load that found the session in Redis.
+	OutcomeHit Outcome = "hit"
+
+	// OutcomeMiss is a load of a session that doesn't exist.
+	OutcomeMiss Outcome = "miss"
+
+	// OutcomeWrite is a save or delete written to Redis.
+	OutcomeWrite Outcome = "write"
+
+	// OutcomeError is an operation that failed.
+	OutcomeError Outcome = "error"
+)
+
+// Event describes a completed store operation.
+type Event struct {
+	Op       Operation
+	Name     string // session (cookie) name
+	Duration time.Duration
+	Bytes    int // serialized payload size, 0 for deletes and misses
+	Outcome  Outcome
+	Err      error
+}
+
+// Observer receives an Event for every load, save and delete of a
+// session. It is called synchronously, and concurrently from the
+// goroutines using the store, so it must be fast and safe for concurrent
+// use.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(e Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// WithObserver reports every load, save and delete to observer, for
+// example an ExpvarObserver.
+//
+// Example:
+//
+//	WithObserver(NewExpvarObserver("redistore"))
+func WithObserver(observer Observer) Option {
+	return func(cfg *storeConfig) error {
+		if observer == nil {
+			return errors.New("observer cannot be nil")
+		}
+		cfg.observer = observer
+		return nil
+	}
+}
+
+// observe reports an operation started at start to the observer, if any.
+func (s *RediStore) observe(op Operation, name string, start time.Time, size int, outcome Outcome, err error) {
+	if s.observer == nil {
+		return
+	}
+	if err != nil {
+		outcome = OutcomeError
+	}
+	s.observer.Observe(Event{
+		Op:       op,
+		Name:     name,
+		Duration: time.Since(start),
+		Bytes:    size,
+		Outcome:  outcome,
+		Err:      err,
+	})
+}
+
+// latencyBounds are the upper bounds of the latency buckets of
+// ExpvarObserver.
+var latencyBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// ExpvarObserver is an Observer publishing counters and latency histograms
+// with the expvar package, under a map with one entry per operation:
+//
+//	{"load": {"count": 42, "errors": 0, "bytes": 5120,
+//	          "outcomes": {"hit": 40, "miss": 2},
+//	          "latency_ms": {"le_1": 30, "le_5": 12, ..., "inf": 0},
+//	          "latency_total_ms": 61.5}, ...}
+//
+// Each operation falls in the first latency bucket whose bound is at least
+// its duration; buckets are not cumulative.
+type ExpvarObserver struct {
+	vars *expvar.Map
+	mu   sync.Mutex // guards the creation of operation maps
+}
+
+// NewExpvarObserver returns an ExpvarObserver publishing under name. Like
+// expvar.NewMap, it panics if name is already published.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	return &ExpvarObserver{vars: expvar.NewMap(name)}
+}
+
+// Observe records e.
+func (o *ExpvarObserver) Observe(e Event) {
+	op := o.operation(e.Op)
+	op.Add("count", 1)
+	if e.Err != nil {
+		op.Add("errors", 1)
+	}
+	op.Add("bytes", int64(e.Bytes))
+	op.Get("outcomes").(*expvar.Map).Add(string(e.Outcome), 1)
+	op.Get("latency_ms").(*expvar.Map).Add(latencyBucket(e.Duration), 1)
+	op.AddFloat("latency_total_ms", float64(e.Duration)/float64(time.Millisecond))
+}
+
+// operation returns the map of an operation, creating it on first use.
+func (o *ExpvarObserver) operation(op Operation) *expvar.Map {
+	if v, ok := o.vars.Get(string(op)).(*expvar.Map); ok {
+		return v
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if v, ok := o.vars.Get(string(op)).(*expvar.Map); ok {
+		return v
+	}
+	m := new(expvar.Map).Init()
+	m.Set("outcomes", new(expvar.Map).Init())
+	m.Set("latency_ms", new(expvar.Map).Init())
+	o.vars.Set(string(op), m)
+	return m
+}
+
+// latencyBucket returns the key of the latency bucket of d.
+func latencyBucket(d time.Duration) string {
+	for _, bound := range latencyBounds {
+		if d <= bound {
+			return "le_" + strconv.FormatInt(bound.Milliseconds(), 10)
+		}
+	}
+	return "inf"
+}
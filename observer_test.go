@@ -0,0 +1,128 @@
+package redistore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObserver(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithLocalCache(100, time.Minute),
+		WithObserver(ObserverFunc(func(e Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		})),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	last := func() Event {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(events) == 0 {
+			t.Fatal("Expected an event")
+		}
+		e := events[len(events)-1]
+		events = nil
+		return e
+	}
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	e := last()
+	if e.Op != OpSave || e.Name != "session-key" || e.Outcome != OutcomeWrite || e.Bytes == 0 || e.Err != nil {
+		t.Errorf("Unexpected save event %+v", e)
+	}
+	size := e.Bytes
+
+	req.Header.Add("Cookie", getCookies(t, rsp)[0])
+	if _, err := store.New(req, "session-key"); err != nil {
+		t.Fatal(err)
+	}
+	if e := last(); e.Op != OpLoad || e.Outcome != OutcomeCacheHit || e.Bytes != size {
+		t.Errorf("Unexpected cached load event %+v", e)
+	}
+
+	if _, err := store.load(context.Background(), store.newStoredSession(newSessionID())); err != nil {
+		t.Fatal(err)
+	}
+	if e := last(); e.Op != OpLoad || e.Outcome != OutcomeMiss || e.Bytes != 0 {
+		t.Errorf("Unexpected miss event %+v", e)
+	}
+
+	session.Values["big"] = strings.Repeat("x", 5000)
+	if err := session.Save(req, NewRecorder()); err == nil {
+		t.Fatal("Expected the session to be too big")
+	}
+	if e := last(); e.Op != OpSave || e.Outcome != OutcomeError || e.Err == nil {
+		t.Errorf("Unexpected error event %+v", e)
+	}
+
+	session.Options.MaxAge = -1
+	if err := session.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if e := last(); e.Op != OpDelete || e.Outcome != OutcomeWrite {
+		t.Errorf("Unexpected delete event %+v", e)
+	}
+}
+
+func TestExpvarObserver(t *testing.T) {
+	o := NewExpvarObserver("redistore_test_" + newSessionID()[:8])
+	o.Observe(Event{Op: OpLoad, Duration: 3 * time.Millisecond, Bytes: 100, Outcome: OutcomeHit})
+	o.Observe(Event{Op: OpLoad, Duration: 2 * time.Second, Outcome: OutcomeError, Err: errors.New("boom")})
+	o.Observe(Event{Op: OpSave, Duration: time.Millisecond, Bytes: 50, Outcome: OutcomeWrite})
+
+	var vars map[string]struct {
+		Count    int64            `json:"count"`
+		Errors   int64            `json:"errors"`
+		Bytes    int64            `json:"bytes"`
+		Outcomes map[string]int64 `json:"outcomes"`
+		Latency  map[string]int64 `json:"latency_ms"`
+	}
+	if err := json.Unmarshal([]byte(o.vars.String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	load := vars["load"]
+	if load.Count != 2 || load.Errors != 1 || load.Bytes != 100 {
+		t.Errorf("Unexpected load counters %+v", load)
+	}
+	if load.Outcomes["hit"] != 1 || load.Outcomes["error"] != 1 {
+		t.Errorf("Unexpected load outcomes %v", load.Outcomes)
+	}
+	if load.Latency["le_5"] != 1 || load.Latency["inf"] != 1 {
+		t.Errorf("Unexpected load latencies %v", load.Latency)
+	}
+	if save := vars["save"]; save.Count != 1 || save.Latency["le_1"] != 1 {
+		t.Errorf("Unexpected save counters %+v", save)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// sessionProfile is the configuration of the sessions of one name.
+type sessionProfile struct {
+	options       *sessions.Options
+	defaultMaxAge int
+	maxLength     int
+	serializer    SessionSerializer
+	keyPrefix     string
+}
+
+// ProfileOption configures a profile registered with WithSessionProfile.
+type ProfileOption func(*sessionProfile) error
+
+// WithProfileSessionOptions sets the cookie options of the profile's
+// sessions, as WithSessionOptions does for the store.
+func WithProfileSessionOptions(opts *sessions.Options) ProfileOption {
+	return func(p *sessionProfile) error {
+		if opts == nil {
+			return errors.New("session options cannot be nil")
+		}
+		options := *opts
+		p.options = &options
+		return nil
+	}
+}
+
+// WithProfileDefaultMaxAge sets the Redis TTL in seconds of the profile's
+// sessions with MaxAge == 0, as WithDefaultMaxAge does for the store.
+func WithProfileDefaultMaxAge(age int) ProfileOption {
+	return func(p *sessionProfile) error {
+		if age < 0 {
+			return fmt.Errorf("default max age cannot be negative, got %d", age)
+		}
+		p.defaultMaxAge = age
+		return nil
+	}
+}
+
+// WithProfileMaxLength sets the size limit of the profile's sessions, as
+// WithMaxLength does for the store. 0 means unlimited.
+func WithProfileMaxLength(length int) ProfileOption {
+	return func(p *sessionProfile) error {
+		if length < 0 {
+			return fmt.Errorf("max length cannot be negative, got %d", length)
+		}
+		p.maxLength = length
+		return nil
+	}
+}
+
+// WithProfileSerializer sets the serializer of the profile's sessions.
+func WithProfileSerializer(serializer SessionSerializer) ProfileOption {
+	return func(p *sessionProfile) error {
+		if serializer == nil {
+			return errors.New("serializer cannot be nil")
+		}
+		p.serializer = serializer
+		return nil
+	}
+}
+
+// WithProfileKeyPrefix sets the Redis key prefix of the profile's
+// sessions.
+func WithProfileKeyPrefix(prefix string) ProfileOption {
+	return func(p *sessionProfile) error {
+		if prefix == "" {
+			return errors.New("key prefix cannot be empty")
+		}
+		p.keyPrefix = prefix
+		return nil
+	}
+}
+
+// WithSessionProfile registers a profile for the sessions of the given
+// cookie name. New, Get, Save and RegenerateID use the profile's cookie
+// options, TTL, size limit, serializer and key prefix for sessions of that
+// name; settings the profile doesn't override are those of the store when
+// NewStore returns.
+//
+// Sessions stored under a profile's own key prefix are not seen by the
+// APIs that work on session IDs alone, such as Sessions, LoadMany, Stats,
+// Export and the admin handler, which use the store's key prefix and
+// serializer. BindUser refuses the sessions of a profile with its own key
+// prefix, since per-user indexes and limits only cover sessions under the
+// store's key prefix, and WithMetadata records no metadata for them.
+//
+// Example:
+//
+//	store, err := NewStore(keyPairs,
+//	    WithAddress("tcp", ":6379"),
+//	    WithSessionProfile("cart",
+//	        WithProfileDefaultMaxAge(7*24*3600),
+//	        WithProfileMaxLength(64*1024),
+//	        WithProfileKeyPrefix("cart_"),
+//	    ),
+//	)
+func WithSessionProfile(name string, opts ...ProfileOption) Option {
+	return func(cfg *storeConfig) error {
+		if name == "" {
+			return errors.New("profile name cannot be empty")
+		}
+		if _, ok := cfg.profiles[name]; ok {
+			return fmt.Errorf("duplicate profile %q", name)
+		}
+		// Check the options now; they are applied to the store's final
+		// configuration by NewStore.
+		var p sessionProfile
+		for _, opt := range opts {
+			if err := opt(&p); err != nil {
+				return fmt.Errorf("profile %q: %w", name, err)
+			}
+		}
+		if cfg.profiles == nil {
+			cfg.profiles = make(map[string][]ProfileOption)
+		}
+		cfg.profiles[name] = opts
+		return nil
+	}
+}
+
+// buildProfiles resolves the registered profiles against the store's
+// configuration.
+func (cfg *storeConfig) buildProfiles() (map[string]*sessionProfile, error) {
+	if len(cfg.profiles) == 0 {
+		return nil, nil
+	}
+	profiles := make(map[string]*sessionProfile, len(cfg.profiles))
+	for name, opts := range cfg.profiles {
+		options := *cfg.sessionOpts
+		p := &sessionProfile{
+			options:       &options,
+			defaultMaxAge: cfg.defaultMaxAge,
+			maxLength:     cfg.maxLength,
+			serializer:    cfg.serializer,
+			keyPrefix:     cfg.keyPrefix,
+		}
+		for _, opt := range opts {
+			if err := opt(p); err != nil {
+				return nil, fmt.Errorf("profile %q: %w", name, err)
+			}
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// profile returns the configuration of the sessions of the given name: its
+// profile if one is registered, else the store's own settings.
+func (s *RediStore) profile(name string) *sessionProfile {
+	if p, ok := s.profiles[name]; ok {
+		return p
+	}
+	return &sessionProfile{
+		options:       s.Options,
+		defaultMaxAge: s.DefaultMaxAge,
+		maxLength:     s.maxLength,
+		serializer:    s.serializer,
+		keyPrefix:     s.keyPrefix,
+	}
+}
+
+// namedSessionKey returns the Redis key of the session with the given name
+// and ID.
+func (s *RediStore) namedSessionKey(name, id string) string {
+	return s.profile(name).keyPrefix + s.storedID(id)
+}
+
+// keyPrefixes returns the key prefixes of the store and its profiles,
+// leaving out those nested under another one.
+func (s *RediStore) keyPrefixes() []string {
+	prefixes := []string{s.keyPrefix}
+	for _, p := range s.profiles {
+		prefixes = append(prefixes, p.keyPrefix)
+	}
+	// Sorted, a prefix comes before those nested under it.
+	slices.Sort(prefixes)
+	kept := prefixes[:1]
+	for _, prefix := range prefixes[1:] {
+		if !strings.HasPrefix(prefix, kept[len(kept)-1]) {
+			kept = append(kept, prefix)
+		}
+	}
+	return kept
+}
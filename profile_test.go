@@ -0,0 +1,149 @@
+package redistore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+func TestSessionProfile(t *testing.T) {
+	addr := setup()
+	prefix := "cart_" + newSessionID()[:8] + "_"
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithSessionProfile("cart",
+			WithProfileSessionOptions(&sessions.Options{Path: "/cart"}),
+			WithProfileDefaultMaxAge(3600),
+			WithProfileMaxLength(128),
+			WithProfileSerializer(JSONSerializer{}),
+			WithProfileKeyPrefix(prefix),
+		),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	conn := store.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	cart, err := store.New(req, "cart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cart.Options.Path != "/cart" || cart.Options.MaxAge != 0 {
+		t.Errorf("Expected the profile's cookie options, got %+v", cart.Options)
+	}
+	cart.Values["items"] = "apples"
+	if err := cart.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The session is stored under the profile's prefix, TTL and serializer.
+	data, err := redis.Bytes(conn.Do("GET", prefix+cart.ID))
+	if err != nil {
+		t.Fatalf("Expected the session under the profile's prefix: %v", err)
+	}
+	if !json.Valid(data) {
+		t.Errorf("Expected a JSON payload, got %q", data)
+	}
+	if ttl, err := redis.Int(conn.Do("TTL", prefix+cart.ID)); err != nil || ttl != 3600 {
+		t.Errorf("Expected the profile's TTL, got %d (%v)", ttl, err)
+	}
+
+	req.Header.Add("Cookie", getCookies(t, rsp)[0])
+	loaded, err := store.New(req, "cart")
+	if err != nil || loaded.IsNew || loaded.Values["items"] != "apples" {
+		t.Errorf("Expected the cart session to load, got %v", err)
+	}
+
+	loaded.Values["items"] = strings.Repeat("apples", 100)
+	if err := loaded.Save(req, NewRecorder()); err == nil {
+		t.Error("Expected the profile's size limit to apply")
+	}
+
+	// User indexes only track sessions under the store's key prefix.
+	if err := store.BindUser(loaded, "user-"+newSessionID()); err == nil {
+		t.Error("Expected BindUser to refuse a session under the profile's prefix")
+	}
+	if UserID(loaded) != "" {
+		t.Errorf("Expected the session to stay unbound, got %q", UserID(loaded))
+	}
+
+	// Other names keep the store's settings.
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	other, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Options.Path != "/" {
+		t.Errorf("Expected the store's cookie options, got %+v", other.Options)
+	}
+	other.Values["items"] = strings.Repeat("apples", 100)
+	if err := other.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := redis.Int(conn.Do("EXISTS", "session_"+other.ID)); err != nil || n != 1 {
+		t.Errorf("Expected the session under the store's prefix, got %d (%v)", n, err)
+	}
+}
+
+func TestWithSessionProfile_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"empty name", WithSessionProfile("")},
+		{"nil options", WithSessionProfile("a", WithProfileSessionOptions(nil))},
+		{"negative max age", WithSessionProfile("a", WithProfileDefaultMaxAge(-1))},
+		{"negative length", WithSessionProfile("a", WithProfileMaxLength(-1))},
+		{"nil serializer", WithSessionProfile("a", WithProfileSerializer(nil))},
+		{"empty prefix", WithSessionProfile("a", WithProfileKeyPrefix(""))},
+	}
+	for _, tt := range tests {
+		if err := tt.opt(defaultConfig()); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+	cfg := defaultConfig()
+	if err := WithSessionProfile("a", WithProfileDefaultMaxAge(0))(cfg); err != nil {
+		t.Errorf("Expected a zero max age to be accepted, as by WithDefaultMaxAge: %v", err)
+	}
+	cfg = defaultConfig()
+	if err := WithSessionProfile("a")(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithSessionProfile("a")(cfg); err == nil {
+		t.Error("Expected error for duplicate profile")
+	}
+}
+
+func TestKeyPrefixes(t *testing.T) {
+	s := &RediStore{keyPrefix: "session_", profiles: map[string]*sessionProfile{
+		"a": {keyPrefix: "session_a_"},
+		"b": {keyPrefix: "cart_"},
+		"c": {keyPrefix: "session_"},
+	}}
+	got := s.keyPrefixes()
+	if strings.Join(got, ",") != "cart_,session_" {
+		t.Errorf("Expected cart_,session_, got %v", got)
+	}
+}
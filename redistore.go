@@ -6,6 +6,7 @@ package redistore
 
 import (
 	"bytes"
+	"context"
 	"encoding/base32"
 	"encoding/gob"
 	"encoding/json"
@@ -14,6 +15,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -163,6 +165,51 @@ type storeConfig struct {
 	defaultMaxAge int
 	serializer    SessionSerializer
 	sessionOpts   *sessions.Options
+
+	// Enumeration
+	scanCount int
+
+	// Session metadata
+	metadata bool
+
+	// Session IDs
+	idGenerator     IDGenerator
+	regenerateGrace time.Duration
+	keyHashSecret   []byte
+
+	// Client binding
+	binding *ClientBinding
+
+	// CSRF tokens
+	csrfHeader string
+	csrfField  string
+
+	// Invalid cookies
+	invalidCookiePolicy InvalidCookiePolicy
+	onInvalidCookie     func(r *http.Request, name string, err error)
+
+	// Per-name session profiles
+	profiles map[string][]ProfileOption
+
+	// Instrumentation
+	observer Observer
+	tracer   Tracer
+
+	// Runtime key rotation
+	keyProvider KeyProvider
+	keyRefresh  time.Duration
+	keyOverlap  time.Duration
+
+	// Per-user session limits
+	maxUserSessions   int
+	userSessionPolicy SessionLimitPolicy
+	onEvict           func(uid string, evicted []string)
+
+	// Local cache configuration
+	cacheSize           int
+	cacheTTL            time.Duration
+	invalidation        InvalidationMode
+	invalidationChannel string
 }
 
 // RediStore represents a session store backed by a Redis database.
@@ -177,6 +224,26 @@ type storeConfig struct {
 //	maxLength: Maximum length of session data.
 //	keyPrefix: Prefix to be added to all Redis keys used by this store.
 //	serializer: Serializer used to encode and decode session data.
+//	profiles: Settings overridden for the sessions of given names.
+//	cache: Optional in-process cache of serialized sessions.
+//	invalidator: Keeps cache consistent with other processes.
+//	loads: Coalesces concurrent loads of the same session.
+//	scanCount: SCAN COUNT hint used when walking the key prefix.
+//	metadata: Whether a metadata record is kept next to each session.
+//	idGenerator: Generates and validates session IDs.
+//	regenerateGrace: How long RegenerateID keeps the old key alive.
+//	keyHashSecret: HMAC secret hashing session IDs into keys, if set.
+//	binding: Client fingerprint recorded and checked with each session.
+//	csrfHeader, csrfField: Where VerifyCSRF reads the token of a request.
+//	invalidCookiePolicy: What New does with cookies that fail to decode.
+//	onInvalidCookie: Called with invalid cookies under InvalidCookieNotify.
+//	invalidCookies: Number of invalid cookies seen by New.
+//	observer: Receives an event for every load, save and delete.
+//	tracer: Starts a span around Redis round trips.
+//	keyring: Codecs loaded from a KeyProvider, if any.
+//	maxUserSessions: Maximum number of sessions bound to the same user.
+//	userSessionPolicy: What to do when maxUserSessions is exceeded.
+//	onEvict: Called with the sessions evicted by userSessionPolicy.
 type RediStore struct {
 	Pool          *redis.Pool
 	Codecs        []securecookie.Codec
@@ -185,6 +252,30 @@ type RediStore struct {
 	maxLength     int
 	keyPrefix     string
 	serializer    SessionSerializer
+	profiles      map[string]*sessionProfile
+	cache         *localCache
+	invalidator   *invalidator
+	loads         flightGroup
+	scanCount     int
+	metadata      bool
+
+	idGenerator     IDGenerator
+	regenerateGrace time.Duration
+	keyHashSecret   []byte
+	binding         *ClientBinding
+	csrfHeader      string
+	csrfField       string
+	keyring         *keyring
+
+	invalidCookiePolicy InvalidCookiePolicy
+	onInvalidCookie     func(r *http.Request, name string, err error)
+	invalidCookies      atomic.Uint64
+	observer            Observer
+	tracer              Tracer
+
+	maxUserSessions   int
+	userSessionPolicy SessionLimitPolicy
+	onEvict           func(uid string, evicted []string)
 }
 
 // WithPool configures the RediStore to use a custom Redis connection pool.
@@ -411,6 +502,11 @@ func defaultConfig() *storeConfig {
 		maxLength:     4096,
 		keyPrefix:     "session_",
 		defaultMaxAge: 60 * 20, // 20 minutes
+		scanCount:     100,
+		keyRefresh:    defaultKeyRefresh,
+		keyOverlap:    defaultKeyOverlap,
+		csrfHeader:    defaultCSRFHeader,
+		csrfField:     defaultCSRFField,
 		serializer:    GobSerializer{},
 		sessionOpts: &sessions.Options{
 			Path:   "/",
@@ -446,6 +542,10 @@ func (cfg *storeConfig) validate() error {
 		)
 	}
 
+	if cfg.invalidCookiePolicy == InvalidCookieNotify && cfg.onInvalidCookie == nil {
+		return errors.New("InvalidCookieNotify requires WithInvalidCookieHandler")
+	}
+
 	return nil
 }
 
@@ -502,7 +602,7 @@ func (cfg *storeConfig) buildPool() (*redis.Pool, error) {
 // Example:
 //
 //	store, err := NewStore(
-//	    Keys([]byte("auth-key"), []byte("encrypt-key")),
+//	    Keys([]byte("an-authentication-key-of-32-bytes"), []byte("the-encryption-key-32-bytes-long")),
 //	    WithAddress("tcp", ":6379"),
 //	)
 func Keys(keys ...[]byte) [][]byte {
@@ -519,17 +619,17 @@ func Keys(keys ...[]byte) [][]byte {
 //
 //	// Single key
 //	store, err := NewStore(
-//	    KeysFromStrings("secret-key"),
+//	    KeysFromStrings("a-secret-key-of-at-least-32-bytes"),
 //	    WithAddress("tcp", ":6379"),
 //	)
 //
 //	// Multiple keys for rotation
 //	store, err := NewStore(
 //	    KeysFromStrings(
-//	        "new-auth-key",
-//	        "new-encrypt-key",
-//	        "old-auth-key",
-//	        "old-encrypt-key",
+//	        "new-authentication-key-of-32-bytes",
+//	        "new-encryption-key-32-bytes-long",
+//	        "old-authentication-key-of-32-bytes",
+//	        "old-encryption-key-32-bytes-long",
 //	    ),
 //	    WithAddress("tcp", ":6379"),
 //	)
@@ -546,8 +646,10 @@ func KeysFromStrings(keys ...string) [][]byte {
 // Parameters:
 //
 //	keyPairs - One or more key pairs for cookie encryption and authentication.
-//	           Each key should be 16, 24, or 32 bytes for AES-128, AES-192, or AES-256.
 //	           Keys are used in pairs: authentication key and encryption key.
+//	           Authentication keys must be at least 32 bytes; encryption keys must
+//	           be 16, 24, or 32 bytes for AES-128, AES-192, or AES-256, or nil.
+//	           Use KeysFromSecret to derive a pair from a master secret.
 //	           Provide multiple pairs for key rotation (first pair is used for encoding,
 //	           remaining pairs are used for decoding only).
 //	opts - Configuration options. At least one connection option is required.
@@ -575,20 +677,47 @@ func KeysFromStrings(keys ...string) [][]byte {
 //   - WithSessionOptions(opts) - Set session options
 //   - WithPath(path) - Set cookie path (default "/")
 //   - WithMaxAge(age) - Set cookie MaxAge (default 30 days)
+//   - WithScanCount(count) - Set SCAN batch size for enumeration (default 100)
+//   - WithMetadata() - Keep a metadata record next to each session
+//   - WithIDGenerator(gen) - Set the session ID generator (default 52 base32 characters)
+//   - WithRegenerateGrace(d) - Keep old keys alive after RegenerateID (default 0)
+//   - WithHashedKeys(secret) - Store sessions under an HMAC of their ID
+//   - WithClientBinding(binding) - Bind sessions to a client fingerprint
+//   - WithCSRFHeader(name) - Set the CSRF token header (default "X-CSRF-Token")
+//   - WithCSRFField(name) - Set the CSRF token form field (default "csrf_token")
+//   - WithInvalidCookiePolicy(policy) - Set what New does with invalid cookies
+//   - WithInvalidCookieHandler(fn) - Report invalid cookies under InvalidCookieNotify
+//   - WithSessionProfile(name, opts...) - Override settings for one session name
+//   - WithObserver(observer) - Report every load, save and delete
+//   - WithTracer(tracer) - Start a span around every Redis round trip
+//
+// Key Options:
+//   - WithKeyProvider(provider) - Load cookie keys at runtime; keyPairs may be nil
+//   - WithKeyRefresh(interval) - Set how often keys are polled (default 1 minute)
+//   - WithKeyOverlap(d) - Keep dropped keys decoding for d (default 24 hours)
+//
+// User Options:
+//   - WithMaxSessionsPerUser(n, policy) - Limit sessions bound to one user
+//   - WithEvictionHandler(fn) - Report sessions evicted by the limit
+//
+// Cache Options:
+//   - WithLocalCache(size, ttl) - Enable an in-process session cache
+//   - WithCacheInvalidation(mode) - Set cache invalidation (default InvalidatePubSub)
+//   - WithInvalidationChannel(channel) - Set the pub/sub invalidation channel
 //
 // Example:
 //
 //	// Basic usage with single key (using helper function)
 //	store, err := NewStore(
-//	    KeysFromStrings("secret-key"),
+//	    KeysFromStrings("a-secret-key-of-at-least-32-bytes"),
 //	    WithAddress("tcp", ":6379"),
 //	)
 //
 //	// Using Keys() with byte slices
 //	store, err := NewStore(
 //	    Keys(
-//	        []byte("authentication-key"), // 32 or 64 bytes
-//	        []byte("encryption-key"),     // 16, 24, or 32 bytes
+//	        []byte("an-authentication-key-of-32-bytes"), // at least 32 bytes
+//	        []byte("the-encryption-key-32-bytes-long"),  // 16, 24, or 32 bytes
 //	    ),
 //	    WithAddress("tcp", ":6379"),
 //	)
@@ -596,10 +725,10 @@ func KeysFromStrings(keys ...string) [][]byte {
 //	// With key rotation (old keys for decoding only)
 //	store, err := NewStore(
 //	    KeysFromStrings(
-//	        "new-auth-key",
-//	        "new-encrypt-key",
-//	        "old-auth-key",    // For decoding existing sessions
-//	        "old-encrypt-key",
+//	        "new-authentication-key-of-32-bytes",
+//	        "new-encryption-key-32-bytes-long",
+//	        "old-authentication-key-of-32-bytes", // For decoding existing sessions
+//	        "old-encryption-key-32-bytes-long",
 //	    ),
 //	    WithAddress("tcp", "localhost:6379"),
 //	    WithDB("1"),
@@ -607,7 +736,7 @@ func KeysFromStrings(keys ...string) [][]byte {
 //
 //	// With multiple options
 //	store, err := NewStore(
-//	    KeysFromStrings("secret-key"),
+//	    KeysFromStrings("a-secret-key-of-at-least-32-bytes"),
 //	    WithAddress("tcp", "localhost:6379"),
 //	    WithDB("1"),
 //	    WithMaxLength(8192),
@@ -617,21 +746,16 @@ func KeysFromStrings(keys ...string) [][]byte {
 //
 //	// Using URL
 //	store, err := NewStore(
-//	    KeysFromStrings("secret-key"),
+//	    KeysFromStrings("a-secret-key-of-at-least-32-bytes"),
 //	    WithURL("redis://:password@localhost:6379/0"),
 //	)
 //
 //	// Without helper functions (direct slice)
 //	store, err := NewStore(
-//	    [][]byte{[]byte("secret-key")},
+//	    [][]byte{[]byte("a-secret-key-of-at-least-32-bytes")},
 //	    WithAddress("tcp", ":6379"),
 //	)
 func NewStore(keyPairs [][]byte, opts ...Option) (*RediStore, error) {
-	// Validate key pairs
-	if len(keyPairs) == 0 {
-		return nil, errors.New("at least one key pair is required")
-	}
-
 	// Start with default configuration
 	cfg := defaultConfig()
 
@@ -642,11 +766,24 @@ func NewStore(keyPairs [][]byte, opts ...Option) (*RediStore, error) {
 		}
 	}
 
+	// Validate key pairs
+	if len(keyPairs) == 0 && cfg.keyProvider == nil {
+		return nil, errors.New("at least one key pair is required")
+	}
+	if err := validateKeyPairs(keyPairs); err != nil {
+		return nil, fmt.Errorf("invalid key pairs: %w", err)
+	}
+
 	// Validate configuration
 	if err := cfg.validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	profiles, err := cfg.buildProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Build connection pool
 	pool, err := cfg.buildPool()
 	if err != nil {
@@ -656,19 +793,61 @@ func NewStore(keyPairs [][]byte, opts ...Option) (*RediStore, error) {
 	// Create RediStore instance
 	rs := &RediStore{
 		Pool:          pool,
-		Codecs:        securecookie.CodecsFromPairs(keyPairs...),
+		Codecs:        withoutMaxAge(securecookie.CodecsFromPairs(keyPairs...)),
 		Options:       cfg.sessionOpts,
 		DefaultMaxAge: cfg.defaultMaxAge,
 		maxLength:     cfg.maxLength,
 		keyPrefix:     cfg.keyPrefix,
 		serializer:    cfg.serializer,
+		profiles:      profiles,
+		scanCount:     cfg.scanCount,
+		metadata:      cfg.metadata,
+
+		idGenerator:     cfg.idGenerator,
+		regenerateGrace: cfg.regenerateGrace,
+		keyHashSecret:   cfg.keyHashSecret,
+		binding:         cfg.binding,
+		csrfHeader:      cfg.csrfHeader,
+		csrfField:       cfg.csrfField,
+
+		invalidCookiePolicy: cfg.invalidCookiePolicy,
+		onInvalidCookie:     cfg.onInvalidCookie,
+		observer:            cfg.observer,
+		tracer:              cfg.tracer,
+
+		maxUserSessions:   cfg.maxUserSessions,
+		userSessionPolicy: cfg.userSessionPolicy,
+		onEvict:           cfg.onEvict,
 	}
 
 	// Test connection
-	if _, err := rs.ping(); err != nil {
+	if _, err := rs.ping(context.Background()); err != nil {
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	// Start local cache and its invalidation listener
+	if cfg.cacheSize > 0 {
+		channel := cfg.invalidationChannel
+		if channel == "" {
+			channel = "redistore:invalidate:" + cfg.keyPrefix
+		}
+		rs.cache = newLocalCache(cfg.cacheSize, cfg.cacheTTL)
+		rs.invalidator = newInvalidator(cfg.invalidation, channel)
+		if err := rs.startInvalidation(); err != nil {
+			return nil, fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+		}
+	}
+
+	// Load cookie keys and keep refreshing them
+	if cfg.keyProvider != nil {
+		k := newKeyring(cfg.keyProvider, cfg.keyRefresh, cfg.keyOverlap)
+		if err := rs.startKeyring(k); err != nil {
+			rs.stopInvalidation()
+			return nil, fmt.Errorf("failed to load keys: %w", err)
+		}
+		rs.Codecs = k.load()
+	}
+
 	return rs, nil
 }
 
@@ -709,20 +888,14 @@ func (s *RediStore) SetSerializer(ss SessionSerializer) {
 //	http://godoc.org/github.com/gorilla/sessions#Options
 //
 // Default is the one provided by this package value - `sessionExpire`.
-// Set it to 0 for no restriction.
-// Because we use `MaxAge` also in SecureCookie crypting algorithm you should
-// use this function to change `MaxAge` value.
+// Set it to 0 for session cookies, which expire with DefaultMaxAge.
+//
+// The codecs are left untouched: the store checks the signed timestamp of
+// each cookie against the MaxAge of its own session, so a session saved
+// with a longer or shorter `Options.MaxAge`, such as a "remember me"
+// cookie, keeps it in both the cookie check and the Redis TTL.
 func (s *RediStore) SetMaxAge(v int) {
-	var c *securecookie.SecureCookie
-	var ok bool
 	s.Options.MaxAge = v
-	for i := range s.Codecs {
-		if c, ok = s.Codecs[i].(*securecookie.SecureCookie); ok {
-			c.MaxAge(v)
-		} else {
-			fmt.Printf("Can't change MaxAge on codec %v\n", s.Codecs[i])
-		}
-	}
 }
 
 func dialClient(network, address, username, password, db string) (redis.Conn, error) {
@@ -757,8 +930,11 @@ func dialClient(network, address, username, password, db string) (redis.Conn, er
 	)
 }
 
-// Close closes the underlying *redis.Pool
+// Close stops the cache invalidation listener, if any, and closes the
+// underlying *redis.Pool
 func (s *RediStore) Close() error {
+	s.stopKeyring()
+	s.stopInvalidation()
 	return s.Pool.Close()
 }
 
@@ -779,14 +955,31 @@ func (s *RediStore) New(r *http.Request, name string) (*sessions.Session, error)
 	)
 	session := sessions.NewSession(s, name)
 	// make a copy
-	options := *s.Options
+	options := *s.profile(name).options
 	session.Options = &options
 	session.IsNew = true
 	if c, errCookie := r.Cookie(name); errCookie == nil {
-		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
-		if err == nil {
-			ok, err = s.load(session)
-			session.IsNew = err != nil || !ok // not new if no error and data available
+		var codec int
+		codec, err = s.decodeCookie(name, c.Value, &session.ID)
+		if err == nil && !s.validID(session.ID) {
+			err = ErrInvalidSessionID
+		}
+		if err != nil {
+			return session, s.invalidCookie(r, session, err)
+		}
+		ok, err = s.load(r.Context(), session)
+		session.IsNew = err != nil || !ok // not new if no error and data available
+		if err == nil && ok {
+			if err := s.checkExpiry(session, c.Value); err != nil {
+				return session, s.invalidCookie(r, session, err)
+			}
+			err = s.checkBinding(r, session)
+		}
+		if err == nil && ok {
+			err = s.touchMetadata(r, session, codec, -1)
+		}
+		if err == nil && ok {
+			s.markStale(r, session, codec)
 		}
 	}
 	return session, err
@@ -794,29 +987,51 @@ func (s *RediStore) New(r *http.Request, name string) (*sessions.Session, error)
 
 // Save adds a single session to the response.
 func (s *RediStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	s.markSaved(r, session)
 	// Marked for deletion.
 	if session.Options.MaxAge < 0 {
-		if err := s.delete(session); err != nil {
+		if err := s.delete(r.Context(), session); err != nil {
 			return err
 		}
 		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
 	} else {
-		// Build an alphanumeric key for the redis store.
-		if session.ID == "" {
-			session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+		if BindingMismatch(session) && s.binding != nil && s.binding.Action == BindingRotate {
+			return s.RegenerateID(r, w, session)
 		}
-		if err := s.save(session); err != nil {
+		if err := s.prepareSave(r, session); err != nil {
 			return err
 		}
-		encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+		if err := s.save(r.Context(), session); err != nil {
+			return err
+		}
+		encoded, codec, err := s.encodeCookie(session.Name(), session.ID)
 		if err != nil {
 			return err
 		}
+		if err := s.touchMetadata(r, session, -1, codec); err != nil {
+			return err
+		}
 		http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
 	}
 	return nil
 }
 
+// prepareSave readies a session for saving: it records the client binding
+// of r, the session's MaxAge, and gives a session without an ID a new one.
+func (s *RediStore) prepareSave(r *http.Request, session *sessions.Session) error {
+	s.recordBinding(r, session, false)
+	s.recordMaxAge(session)
+	// Build an alphanumeric key for the redis store.
+	if session.ID == "" {
+		id, err := s.newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	return nil
+}
+
 // Delete removes the session from redis, and sets the cookie to expire.
 //
 // WARNING: This method should be considered deprecated since it is not exposed via the gorilla/sessions interface.
@@ -826,13 +1041,7 @@ func (s *RediStore) Delete(
 	w http.ResponseWriter,
 	session *sessions.Session,
 ) error {
-	conn := s.Pool.Get()
-	defer func() {
-		if err := conn.Close(); err != nil {
-			fmt.Printf("Error closing connection: %v\n", err)
-		}
-	}()
-	if _, err := conn.Do("DEL", s.keyPrefix+session.ID); err != nil {
+	if err := s.delete(r.Context(), session); err != nil {
 		return err
 	}
 	// Set cookie to expire.
@@ -846,8 +1055,26 @@ func (s *RediStore) Delete(
 	return nil
 }
 
+// sendDelete queues the deletion of the session and its metadata on conn,
+// along with its removal from the user's index if it is bound, and returns
+// the number of commands sent.
+func (s *RediStore) sendDelete(conn redis.Conn, session *sessions.Session) (int, error) {
+	key, meta := s.namedSessionKey(session.Name(), session.ID), s.namedMetadataKey(session.Name(), session.ID)
+	if err := conn.Send("DEL", key, meta); err != nil {
+		return 0, err
+	}
+	uid := UserID(session)
+	if uid == "" {
+		return 1, nil
+	}
+	// sendUnindex sends two commands.
+	return 3, s.sendUnindex(conn, uid, session.ID)
+}
+
 // ping does an internal ping against a server to check if it is alive.
-func (s *RediStore) ping() (bool, error) {
+func (s *RediStore) ping(ctx context.Context) (ok bool, err error) {
+	span := s.startSpan(ctx, OpPing, SpanAttributes{Command: "PING"})
+	defer func() { span.Finish(0, err) }()
 	conn := s.Pool.Get()
 	defer func() {
 		if err := conn.Close(); err != nil {
@@ -861,15 +1088,42 @@ func (s *RediStore) ping() (bool, error) {
 	return (data == "PONG"), nil
 }
 
+// newSessionID returns a random alphanumeric session ID.
+func newSessionID() string {
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+}
+
+// encode serializes the session and enforces maxLength on the result.
+func (s *RediStore) encode(session *sessions.Session) ([]byte, error) {
+	p := s.profile(session.Name())
+	b, err := p.serializer.Serialize(session)
+	if err != nil {
+		return nil, err
+	}
+	if p.maxLength != 0 && len(b) > p.maxLength {
+		return nil, errors.New("SessionStore: the value to store is too big")
+	}
+	return b, nil
+}
+
+// ttl returns the Redis TTL in seconds for the session, falling back to
+// the DefaultMaxAge of its profile for session cookies (MaxAge == 0).
+func (s *RediStore) ttl(session *sessions.Session) int {
+	if session.Options.MaxAge == 0 {
+		return s.profile(session.Name()).defaultMaxAge
+	}
+	return session.Options.MaxAge
+}
+
 // save stores the session in redis.
-func (s *RediStore) save(session *sessions.Session) error {
-	b, err := s.serializer.Serialize(session)
+func (s *RediStore) save(ctx context.Context, session *sessions.Session) (err error) {
+	start, size := time.Now(), 0
+	defer func() { s.observe(OpSave, session.Name(), start, size, OutcomeWrite, err) }()
+	b, err := s.encode(session)
 	if err != nil {
 		return err
 	}
-	if s.maxLength != 0 && len(b) > s.maxLength {
-		return errors.New("SessionStore: the value to store is too big")
-	}
+	size = len(b)
 	conn := s.Pool.Get()
 	defer func() {
 		if err := conn.Close(); err != nil {
@@ -879,17 +1133,67 @@ func (s *RediStore) save(session *sessions.Session) error {
 	if err = conn.Err(); err != nil {
 		return err
 	}
-	age := session.Options.MaxAge
-	if age == 0 {
-		age = s.DefaultMaxAge
+	p := s.profile(session.Name())
+	key := p.keyPrefix + s.storedID(session.ID)
+	changed := []string{key}
+	attrs := SpanAttributes{Command: "SETEX", KeyPrefix: p.keyPrefix, SessionName: session.Name()}
+	if uid := UserID(session); uid != "" {
+		attrs.Command = "EVALSHA"
+		span := s.startSpan(ctx, OpSave, attrs)
+		reply, err := saveBoundScript.Do(conn, s.boundSaveArgs(uid, key, session.ID, s.ttl(session), b)...)
+		span.Finish(len(b), err)
+		if err != nil {
+			return err
+		}
+		evicted, err := s.boundSaveResult(uid, reply)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, evicted...)
+	} else {
+		span := s.startSpan(ctx, OpSave, attrs)
+		_, err = conn.Do("SETEX", key, s.ttl(session), b)
+		span.Finish(len(b), err)
+		if err != nil {
+			return err
+		}
 	}
-	_, err = conn.Do("SETEX", s.keyPrefix+session.ID, age, b)
-	return err
+	s.cache.set(key, b, time.Duration(s.ttl(session))*time.Second)
+	return s.publishInvalidation(conn, changed...)
 }
 
 // load reads the session from redis.
 // returns true if there is a sessoin data in DB
-func (s *RediStore) load(session *sessions.Session) (bool, error) {
+//
+// Concurrent loads of the same session share a single round trip; each
+// caller deserializes its own copy of the payload into its session.
+func (s *RediStore) load(ctx context.Context, session *sessions.Session) (found bool, err error) {
+	start, size, outcome := time.Now(), 0, OutcomeMiss
+	defer func() { s.observe(OpLoad, session.Name(), start, size, outcome, err) }()
+	p := s.profile(session.Name())
+	key := p.keyPrefix + s.storedID(session.ID)
+	if b, ok := s.cache.get(key); ok {
+		size, outcome = len(b), OutcomeCacheHit
+		return true, p.serializer.Deserialize(b, session)
+	}
+	span := s.startSpan(ctx, OpLoad, SpanAttributes{Command: "GET", KeyPrefix: p.keyPrefix, SessionName: session.Name()})
+	b, err := s.loads.do(key, func() ([]byte, error) {
+		return s.fetch(key)
+	})
+	span.Finish(len(b), err)
+	if err != nil || b == nil {
+		return false, err
+	}
+	size, outcome = len(b), OutcomeHit
+	return true, p.serializer.Deserialize(b, session)
+}
+
+// fetch reads the raw payload stored under key, filling the local cache.
+// It returns nil if no data is associated with the key.
+func (s *RediStore) fetch(key string) (b []byte, err error) {
+	var ttl time.Duration
+	s.cache.beginFill(key)
+	defer func() { s.cache.endFill(key, b, ttl) }()
 	conn := s.Pool.Get()
 	defer func() {
 		if err := conn.Close(); err != nil {
@@ -897,32 +1201,64 @@ func (s *RediStore) load(session *sessions.Session) (bool, error) {
 		}
 	}()
 	if err := conn.Err(); err != nil {
-		return false, err
+		return nil, err
 	}
-	data, err := conn.Do("GET", s.keyPrefix+session.ID)
-	if err != nil {
-		return false, err
+	if s.cache == nil {
+		data, err := conn.Do("GET", key)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return nil, nil // no data was associated with this key
+		}
+		return redis.Bytes(data, err)
 	}
-	if data == nil {
-		return false, nil // no data was associated with this key
+	// Read the remaining TTL in the same round trip, so that the cached
+	// entry expires with the key even without an invalidation message.
+	if err := conn.Send("GET", key); err != nil {
+		return nil, err
 	}
-	b, err := redis.Bytes(data, err)
+	if err := conn.Send("PTTL", key); err != nil {
+		return nil, err
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	data, err := conn.Receive()
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	ms, err := redis.Int64(conn.Receive())
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil // no data was associated with this key
 	}
-	return true, s.serializer.Deserialize(b, session)
+	ttl = time.Duration(ms) * time.Millisecond
+	return redis.Bytes(data, err)
 }
 
 // delete removes keys from redis if MaxAge<0
-func (s *RediStore) delete(session *sessions.Session) error {
+func (s *RediStore) delete(ctx context.Context, session *sessions.Session) (err error) {
+	start := time.Now()
+	defer func() { s.observe(OpDelete, session.Name(), start, 0, OutcomeWrite, err) }()
 	conn := s.Pool.Get()
 	defer func() {
 		if err := conn.Close(); err != nil {
 			fmt.Printf("Error closing connection: %v\n", err)
 		}
 	}()
-	if _, err := conn.Do("DEL", s.keyPrefix+session.ID); err != nil {
+	p := s.profile(session.Name())
+	key := p.keyPrefix + s.storedID(session.ID)
+	span := s.startSpan(ctx, OpDelete, SpanAttributes{Command: "DEL", KeyPrefix: p.keyPrefix, SessionName: session.Name()})
+	if _, err = s.sendDelete(conn, session); err == nil {
+		_, err = conn.Do("")
+	}
+	span.Finish(0, err)
+	if err != nil {
 		return err
 	}
-	return nil
+	s.cache.invalidate(key)
+	return s.publishInvalidation(conn, key)
 }
@@ -17,7 +17,7 @@ const (
 // when no connection option is provided
 func TestNewStore_NoConnectionOption(t *testing.T) {
 	_, err := NewStore(
-		[][]byte{[]byte("secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithMaxLength(8192),
 	)
 	if err == nil {
@@ -34,7 +34,7 @@ func TestNewStore_NoConnectionOption(t *testing.T) {
 // when multiple connection options are provided
 func TestNewStore_MultipleConnectionOptions(t *testing.T) {
 	_, err := NewStore(
-		[][]byte{[]byte("secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithAddress("tcp", ":6379"),
 		WithURL("redis://localhost:6379"),
 	)
@@ -346,9 +346,9 @@ func TestNewStore_KeyRotation(t *testing.T) {
 	_, err := NewStore(
 		[][]byte{
 			[]byte("new-authentication-key-32-bytes!"),
-			[]byte("new-encrypt-key-32-bytes-long!"),
+			[]byte("new-encrypt-key-32-bytes-long!!!"),
 			[]byte("old-authentication-key-32-bytes!"),
-			[]byte("old-encrypt-key-32-bytes-long!"),
+			[]byte("old-encrypt-key-32-bytes-long!!!"),
 		},
 		WithAddress("tcp", ":6379"),
 	)
@@ -363,7 +363,7 @@ func TestNewStore_KeyRotation(t *testing.T) {
 func TestNewStore_SingleKey(t *testing.T) {
 	// Test with a single key
 	_, err := NewStore(
-		[][]byte{[]byte("single-secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithAddress("tcp", ":6379"),
 	)
 
@@ -397,7 +397,7 @@ func TestKeys(t *testing.T) {
 
 	// Use Keys with NewStore
 	_, err := NewStore(
-		Keys([]byte("test-key")),
+		Keys([]byte(testHashKey)),
 		WithAddress("tcp", ":6379"),
 	)
 	// Error expected if Redis is not running
@@ -433,7 +433,7 @@ func TestKeysFromStrings(t *testing.T) {
 
 	// Use KeysFromStrings with NewStore
 	_, err := NewStore(
-		KeysFromStrings("test-key"),
+		KeysFromStrings(testHashKey),
 		WithAddress("tcp", ":6379"),
 	)
 	// Error expected if Redis is not running
@@ -20,6 +20,7 @@ const (
 	testFlashFoo     = "foo"
 	testFlashBar     = "bar"
 	testFlashBaz     = "baz"
+	testHashKey      = "secret-key-for-tests-0123456789ab"
 )
 
 func setup() string {
@@ -100,7 +101,7 @@ type FlashMessage struct {
 func createTestStore(t *testing.T, addr string) *RediStore {
 	t.Helper()
 	store, err := NewStore(
-		[][]byte{[]byte("secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithAddress("tcp", addr),
 		WithPoolSize(10),
 	)
@@ -113,7 +114,7 @@ func createTestStore(t *testing.T, addr string) *RediStore {
 func createTestStoreWithDB(t *testing.T, addr, db string) *RediStore {
 	t.Helper()
 	store, err := NewStore(
-		[][]byte{[]byte("secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithAddress("tcp", addr),
 		WithDB(db),
 		WithPoolSize(10),
@@ -359,7 +360,7 @@ func TestRediStore(t *testing.T) {
 
 func TestPingGoodPort(t *testing.T) {
 	store, err := NewStore(
-		[][]byte{[]byte("secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithAddress("tcp", ":6379"),
 		WithPoolSize(10),
 	)
@@ -371,7 +372,7 @@ func TestPingGoodPort(t *testing.T) {
 			fmt.Printf("Error closing store: %v\n", err)
 		}
 	}()
-	ok, err := store.ping()
+	ok, err := store.ping(context.Background())
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -382,7 +383,7 @@ func TestPingGoodPort(t *testing.T) {
 
 func TestPingBadPort(t *testing.T) {
 	store, err := NewStore(
-		[][]byte{[]byte("secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithAddress("tcp", ":6378"),
 		WithPoolSize(10),
 	)
@@ -393,7 +394,7 @@ func TestPingBadPort(t *testing.T) {
 				fmt.Printf("Error closing store: %v\n", err)
 			}
 		}()
-		_, pingErr := store.ping()
+		_, pingErr := store.ping(context.Background())
 		if pingErr == nil {
 			t.Error("Expected error connecting to bad port")
 		}
@@ -403,7 +404,7 @@ func TestPingBadPort(t *testing.T) {
 func TestNewStore_WithURL(t *testing.T) {
 	t.Run("Valid URL", func(t *testing.T) {
 		store, err := NewStore(
-			[][]byte{[]byte("secret-key")},
+			[][]byte{[]byte(testHashKey)},
 			WithURL("redis://localhost:6379"),
 			WithPoolSize(10),
 		)
@@ -431,7 +432,7 @@ func TestNewStore_WithURL(t *testing.T) {
 
 	t.Run("Invalid URL", func(t *testing.T) {
 		_, err := NewStore(
-			[][]byte{[]byte("secret-key")},
+			[][]byte{[]byte(testHashKey)},
 			WithURL("invalid-url"),
 			WithPoolSize(10),
 		)
@@ -571,7 +572,7 @@ func TestSessionCookieMaxAgeZero(t *testing.T) {
 func ExampleRediStore() {
 	// RedisStore
 	store, err := NewStore(
-		[][]byte{[]byte("secret-key")},
+		[][]byte{[]byte(testHashKey)},
 		WithAddress("tcp", ":6379"),
 		WithPoolSize(10),
 	)
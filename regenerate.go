@@ -0,0 +1,221 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+// WithRegenerateGrace keeps the old key of a session alive for d after
+// RegenerateID moves it to a new ID, so that requests already in flight
+// with the old cookie still find their session. Default is 0, the old key
+// is deleted at once.
+//
+// Example:
+//
+//	WithRegenerateGrace(10 * time.Second)
+func WithRegenerateGrace(d time.Duration) Option {
+	return func(cfg *storeConfig) error {
+		if d < 0 {
+			return fmt.Errorf("regenerate grace cannot be negative, got %v", d)
+		}
+		cfg.regenerateGrace = d
+		return nil
+	}
+}
+
+// regenerateScript moves a session to a new ID atomically: it stores the
+// payload under the new key, expires or deletes the old key, moves the
+// metadata and, for sessions bound to a user, swaps the ID in the user's
+// indexes keeping its creation time. A bound session that isn't in the
+// user's index yet, as when BindUser precedes RegenerateID at login, is
+// subject to the per-user session limit, see enforceLimitLua.
+//
+// The script returns {"ok", evicted IDs...} on success or {"rejected"} if
+// the session was refused, in which case nothing is changed.
+//
+// KEYS[1] - old session key
+// KEYS[2] - new session key
+// KEYS[3] - old metadata key
+// KEYS[4] - new metadata key
+// KEYS[5] - user index key (optional)
+// KEYS[6] - user creation index key (optional)
+// ARGV[1] - payload
+// ARGV[2] - TTL in seconds
+// ARGV[3] - grace period in milliseconds, 0 to delete the old key
+// ARGV[4] - old session ID
+// ARGV[5] - new session ID
+// ARGV[6] - current unix time in milliseconds
+// ARGV[7] - maximum number of sessions, 0 for no limit
+// ARGV[8] - 1 to reject new sessions over the limit, 0 to evict
+// ARGV[9] - key prefix of indexed sessions
+// ARGV[10] - key prefix of the metadata of indexed sessions
+var regenerateScript = redis.NewScript(-1, enforceLimitLua+`
+local ttl = tonumber(ARGV[2])
+local grace = tonumber(ARGV[3])
+local nowMs = tonumber(ARGV[6])
+local result = {'ok'}
+if #KEYS == 6 then
+	result = enforceLimit(KEYS[5], KEYS[6], ARGV[4], math.floor(nowMs / 1000),
+		tonumber(ARGV[7]), ARGV[8] == '1', ARGV[9], ARGV[10])
+	if result[1] == 'rejected' then
+		return result
+	end
+end
+redis.call('SETEX', KEYS[2], ttl, ARGV[1])
+if grace == 0 then
+	redis.call('DEL', KEYS[1])
+elseif redis.call('PTTL', KEYS[1]) > grace then
+	redis.call('PEXPIRE', KEYS[1], grace)
+end
+if redis.call('EXISTS', KEYS[3]) == 1 then
+	redis.call('RENAME', KEYS[3], KEYS[4])
+	redis.call('PEXPIRE', KEYS[4], ttl * 1000)
+end
+if #KEYS == 6 then
+	local created = redis.call('ZSCORE', KEYS[6], ARGV[4]) or nowMs
+	redis.call('ZREM', KEYS[5], ARGV[4])
+	redis.call('ZREM', KEYS[6], ARGV[4])
+	redis.call('ZADD', KEYS[5], math.floor(nowMs / 1000) + ttl, ARGV[5])
+	redis.call('ZADD', KEYS[6], created, ARGV[5])
+	local last = redis.call('ZRANGE', KEYS[5], -1, -1, 'WITHSCORES')
+	local expireAt = math.ceil(tonumber(last[2]))
+	redis.call('EXPIREAT', KEYS[5], expireAt)
+	redis.call('EXPIREAT', KEYS[6], expireAt)
+end
+return result
+`)
+
+// regeneratedValueKeys are the internal session values RegenerateID
+// updates before storing the session.
+var regeneratedValueKeys = []interface{}{
+	fingerprintValueKey, bindingMismatchValueKey, csrfValueKey, maxAgeValueKey,
+}
+
+// snapshotValues returns a function that restores the given values of the
+// session to their current state, deleting those currently unset.
+func snapshotValues(session *sessions.Session, keys ...interface{}) func() {
+	saved := make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		if v, ok := session.Values[key]; ok {
+			saved[key] = v
+		}
+	}
+	return func() {
+		for _, key := range keys {
+			if v, ok := saved[key]; ok {
+				session.Values[key] = v
+			} else {
+				delete(session.Values, key)
+			}
+		}
+	}
+}
+
+// RegenerateID moves the session to a freshly generated ID and writes the
+// new cookie to w, to prevent session fixation. Call it after the user
+// logs in or their privileges change.
+//
+// The session's current values are stored under the new ID and the old
+// key is deleted in a single atomic step, or kept for the grace period set
+// with WithRegenerateGrace. Metadata and user indexes follow the session,
+// and with WithClientBinding the session is bound to the current client.
+// The CSRF secret of the session, if any, is replaced.
+// A session that was never saved is simply saved with a new ID.
+//
+// A session bound with BindUser since it was last saved joins the user's
+// index here, under WithMaxSessionsPerUser: the user's oldest sessions
+// may be evicted, or ErrTooManySessions returned and nothing changed.
+// The session is left as it was whenever it couldn't be stored.
+//
+// Example:
+//
+//	session, _ := store.Get(r, "session-key")
+//	session.Values["user"] = user.ID
+//	if err := store.RegenerateID(r, w, session); err != nil {
+//	    http.Error(w, err.Error(), http.StatusInternalServerError)
+//	    return
+//	}
+func (s *RediStore) RegenerateID(r *http.Request, w http.ResponseWriter, session *sessions.Session) (err error) {
+	s.markSaved(r, session)
+	restore, stored := snapshotValues(session, regeneratedValueKeys...), false
+	defer func() {
+		if err != nil && !stored {
+			restore()
+		}
+	}()
+	s.recordBinding(r, session, true)
+	if err := rotateCSRF(session); err != nil {
+		return err
+	}
+	s.recordMaxAge(session)
+	if session.ID == "" {
+		return s.Save(r, w, session)
+	}
+	b, err := s.encode(session)
+	if err != nil {
+		return err
+	}
+	newID, err := s.newID()
+	if err != nil {
+		return err
+	}
+	oldID := session.ID
+	oldKey, newKey := s.namedSessionKey(session.Name(), oldID), s.namedSessionKey(session.Name(), newID)
+
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return err
+	}
+	uid := UserID(session)
+	oldMeta, newMeta := s.namedMetadataKey(session.Name(), oldID), s.namedMetadataKey(session.Name(), newID)
+	args := []interface{}{4, oldKey, newKey, oldMeta, newMeta}
+	if uid != "" {
+		args[0] = 6
+		args = append(args, s.userIndexKey(uid), s.userCreatedKey(uid))
+	}
+	args = append(args, b, s.ttl(session), s.regenerateGrace.Milliseconds(),
+		s.storedID(oldID), s.storedID(newID), time.Now().UnixMilli())
+	args = append(args, s.userLimitArgs()...)
+	reply, err := regenerateScript.Do(conn, args...)
+	if err != nil {
+		return err
+	}
+	changed := []string{oldKey, newKey}
+	if uid != "" {
+		evicted, err := s.boundSaveResult(uid, reply)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, evicted...)
+	}
+	stored = true
+	session.ID = newID
+	s.cache.invalidate(oldKey)
+	s.cache.set(newKey, b, time.Duration(s.ttl(session))*time.Second)
+	if err := s.publishInvalidation(conn, changed...); err != nil {
+		return err
+	}
+
+	encoded, codec, err := s.encodeCookie(session.Name(), session.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.touchMetadata(r, session, -1, codec); err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
@@ -0,0 +1,197 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRegenerateID(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithMetadata(),
+		WithRegenerateGrace(5*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	uid := "user-" + newSessionID()
+
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["cart"] = "apples"
+	if err := store.BindUser(session, uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	oldID := session.ID
+
+	session.Values["user"] = "alice"
+	rsp = NewRecorder()
+	if err := store.RegenerateID(req, rsp, session); err != nil {
+		t.Fatalf("RegenerateID failed: %v", err)
+	}
+	if session.ID == oldID || session.ID == "" {
+		t.Fatalf("Expected a new ID, got %q", session.ID)
+	}
+
+	// The new cookie leads to the session with its current values.
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", getCookies(t, rsp)[0])
+	loaded, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IsNew || loaded.ID != session.ID {
+		t.Fatalf("Expected session %s from the new cookie, got %q", session.ID, loaded.ID)
+	}
+	if loaded.Values["cart"] != "apples" || loaded.Values["user"] != "alice" {
+		t.Errorf("Unexpected values %v", loaded.Values)
+	}
+
+	// The old key lives on for the grace period only.
+	conn := store.Pool.Get()
+	defer conn.Close()
+	pttl, _ := conn.Do("PTTL", store.keyPrefix+oldID)
+	if ms, _ := pttl.(int64); ms <= 0 || ms > 5000 {
+		t.Errorf("Expected the old key to expire within the grace period, got PTTL %v", pttl)
+	}
+
+	if _, err := store.Metadata(session.ID); err != nil {
+		t.Errorf("Expected metadata to follow the session: %v", err)
+	}
+	listed, err := store.ListUserSessions(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(listed, []string{session.ID}) {
+		t.Errorf("Expected the user index to hold only %s, got %v", session.ID, listed)
+	}
+
+	// Without a grace period the old key is deleted at once.
+	store.regenerateGrace = 0
+	prevID := session.ID
+	if err := store.RegenerateID(req, NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := conn.Do("EXISTS", store.keyPrefix+prevID); exists != int64(0) {
+		t.Error("Expected the old key to be deleted")
+	}
+}
+
+func TestRegenerateID_MaxSessionsPerUser(t *testing.T) {
+	addr := setup()
+	var evicted []string
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithMaxSessionsPerUser(1, RejectNew),
+		WithEvictionHandler(func(uid string, ids []string) {
+			evicted = append(evicted, ids...)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	uid := "user-" + newSessionID()
+
+	// Log in as documented: save the anonymous session, bind it and
+	// regenerate its ID.
+	login := func() (string, error) {
+		req, _ := http.NewRequestWithContext(
+			context.Background(), "GET", "http://localhost:8080/", nil)
+		session, err := store.New(req, "session-key")
+		if err != nil {
+			return "", err
+		}
+		if _, err := store.CSRFToken(session); err != nil {
+			return "", err
+		}
+		if err := session.Save(req, NewRecorder()); err != nil {
+			return "", err
+		}
+		oldID := session.ID
+		if err := store.BindUser(session, uid); err != nil {
+			return "", err
+		}
+		session.Options.MaxAge = 3600
+		values := maps.Clone(session.Values)
+		// Creation order is tracked with millisecond precision.
+		time.Sleep(2 * time.Millisecond)
+		if err := store.RegenerateID(req, NewRecorder(), session); err != nil {
+			if session.ID != oldID {
+				t.Errorf("Expected a rejected session to keep its ID")
+			}
+			if !reflect.DeepEqual(session.Values, values) {
+				t.Errorf("Expected a rejected session to keep its values, got %v", session.Values)
+			}
+			return "", err
+		}
+		return session.ID, nil
+	}
+
+	first, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(); err != ErrTooManySessions {
+		t.Errorf("Expected ErrTooManySessions, got %v", err)
+	}
+	if listed, _ := store.ListUserSessions(uid); !slices.Equal(listed, []string{first}) {
+		t.Errorf("Expected only %s to be indexed, got %v", first, listed)
+	}
+
+	store.userSessionPolicy = EvictOldest
+	second, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(evicted, []string{first}) {
+		t.Errorf("Expected %s to be evicted, got %v", first, evicted)
+	}
+	if listed, _ := store.ListUserSessions(uid); !slices.Equal(listed, []string{second}) {
+		t.Errorf("Expected only %s to be indexed, got %v", second, listed)
+	}
+	// Regenerating a session already in the index doesn't count twice.
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	session := store.newStoredSession(second)
+	session.Values[userValueKey] = uid
+	if err := store.RegenerateID(req, NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 1 {
+		t.Errorf("Expected no more evictions, got %v", evicted)
+	}
+}
+
+func TestWithRegenerateGrace_Invalid(t *testing.T) {
+	if err := WithRegenerateGrace(-time.Second)(defaultConfig()); err == nil {
+		t.Error("Expected error for negative grace period")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gorilla/sessions"
+)
+
+// reissueKey is the request context key of the pending reissues.
+type reissueKey struct{}
+
+// reissues holds the sessions of a request whose cookie was decoded by an
+// older codec, or was invalid, and that haven't been saved since.
+type reissues struct {
+	mu       sync.Mutex
+	sessions []*sessions.Session
+	done     bool
+}
+
+// add records a session to reissue, unless the response was already
+// started.
+func (p *reissues) add(session *sessions.Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.done && !slices.Contains(p.sessions, session) {
+		p.sessions = append(p.sessions, session)
+	}
+}
+
+// remove forgets a session whose cookie was written by Save.
+func (p *reissues) remove(session *sessions.Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions = slices.DeleteFunc(p.sessions, func(s *sessions.Session) bool {
+		return s == session
+	})
+}
+
+// take returns the pending sessions, once.
+func (p *reissues) take() []*sessions.Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return nil
+	}
+	p.done = true
+	return p.sessions
+}
+
+// reissueWriter writes the pending cookies before the response headers.
+type reissueWriter struct {
+	http.ResponseWriter
+	store   *RediStore
+	r       *http.Request
+	pending *reissues
+}
+
+func (w *reissueWriter) WriteHeader(code int) {
+	w.store.reissue(w.r, w.ResponseWriter, w.pending.take())
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *reissueWriter) Write(b []byte) (int, error) {
+	w.store.reissue(w.r, w.ResponseWriter, w.pending.take())
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush writes the pending cookies, since flushing sends the headers, and
+// flushes the underlying ResponseWriter if it supports it.
+func (w *reissueWriter) Flush() {
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+	w.store.reissue(w.r, w.ResponseWriter, w.pending.take())
+	flusher.Flush()
+}
+
+// Hijack hands the connection over to the caller, if the underlying
+// ResponseWriter supports it. Pending cookies are dropped, as the headers
+// are no longer written by the server.
+func (w *reissueWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("redistore: %T does not support hijacking: %w", w.ResponseWriter, http.ErrNotSupported)
+	}
+	w.pending.take()
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *reissueWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ReissueCookies returns a handler that re-encodes with the primary codec
+// the cookies of sessions loaded by next with an older codec, even if
+// next never saves them. The cookies are added to the response just
+// before its headers are written.
+//
+// Save always encodes the cookie with the primary codec, so sessions that
+// are saved move to it on their own. Wrapping handlers with ReissueCookies
+// also moves those that are only read, so that old key pairs stop being
+// needed within one MaxAge of rotating them. It also clears invalid
+// cookies under InvalidCookieReset and InvalidCookieNotify.
+//
+// Example:
+//
+//	http.ListenAndServe(":8080", store.ReissueCookies(mux))
+func (s *RediStore) ReissueCookies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pending := &reissues{}
+		r = r.WithContext(context.WithValue(r.Context(), reissueKey{}, pending))
+		rw := &reissueWriter{ResponseWriter: w, store: s, r: r, pending: pending}
+		next.ServeHTTP(rw, r)
+		// Handlers that write nothing still get a response.
+		s.reissue(r, w, pending.take())
+	})
+}
+
+// pendingReissues returns the reissues of the request, if it is served by
+// ReissueCookies.
+func pendingReissues(r *http.Request) *reissues {
+	pending, _ := r.Context().Value(reissueKey{}).(*reissues)
+	return pending
+}
+
+// markStale queues the reissue of the cookie of session if it was decoded
+// by an older codec than the primary one, at index codec.
+func (s *RediStore) markStale(r *http.Request, session *sessions.Session, codec int) {
+	if codec <= 0 {
+		return
+	}
+	if pending := pendingReissues(r); pending != nil {
+		pending.add(session)
+	}
+}
+
+// markSaved cancels the reissue of a session whose cookie is being
+// written.
+func (s *RediStore) markSaved(r *http.Request, session *sessions.Session) {
+	if pending := pendingReissues(r); pending != nil {
+		pending.remove(session)
+	}
+}
+
+// reissue writes the cookies of sessions with the primary codec, or clears
+// them for sessions without an ID. Errors are printed, as the response
+// can't report them.
+func (s *RediStore) reissue(r *http.Request, w http.ResponseWriter, stale []*sessions.Session) {
+	for _, session := range stale {
+		if session.Options.MaxAge < 0 {
+			continue
+		}
+		if session.ID == "" {
+			// An invalid cookie whose new session wasn't saved.
+			options := *session.Options
+			options.MaxAge = -1
+			http.SetCookie(w, sessions.NewCookie(session.Name(), "", &options))
+			continue
+		}
+		encoded, codec, err := s.encodeCookie(session.Name(), session.ID)
+		if err != nil {
+			fmt.Printf("Error reissuing cookie %s: %v\n", session.Name(), err)
+			continue
+		}
+		if err := s.touchMetadata(r, session, -1, codec); err != nil {
+			fmt.Printf("Error reissuing cookie %s: %v\n", session.Name(), err)
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	}
+}
@@ -0,0 +1,177 @@
+package redistore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+)
+
+func TestReissueCookies(t *testing.T) {
+	addr := setup()
+	oldStore, err := NewStore(KeysFromStrings("old-hash-key-0123456789abcdef012"), WithAddress("tcp", addr))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := oldStore.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	store, err := NewStore(Keys([]byte("new-hash-key-0123456789abcdef012"), nil, []byte("old-hash-key-0123456789abcdef012"), nil), WithAddress("tcp", addr))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	// A cookie encoded with the old key only.
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := oldStore.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	oldCookie := getCookies(t, rsp)[0]
+	id := session.ID
+
+	primary := securecookie.New([]byte("new-hash-key-0123456789abcdef012"), nil)
+	serve := func(cookie string, h http.HandlerFunc) *ResponseRecorder {
+		req, _ := http.NewRequestWithContext(
+			context.Background(), "GET", "http://localhost:8080/", nil)
+		req.Header.Add("Cookie", cookie)
+		rsp := NewRecorder()
+		store.ReissueCookies(h).ServeHTTP(rsp, req)
+		return rsp
+	}
+	decodesWithPrimary := func(rsp *ResponseRecorder) bool {
+		req, _ := http.NewRequestWithContext(
+			context.Background(), "GET", "http://localhost:8080/", nil)
+		req.Header.Add("Cookie", getCookies(t, rsp)[0])
+		c, err := req.Cookie("session-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		return primary.Decode("session-key", c.Value, &got) == nil && got == id
+	}
+
+	// Read-only requests get the cookie re-encoded with the primary key.
+	rsp = serve(oldCookie, func(w http.ResponseWriter, r *http.Request) {
+		s, err := store.Get(r, "session-key")
+		if err != nil || s.Values["name"] != "alice" {
+			t.Errorf("Expected the old cookie to decode, got %v", err)
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	if !decodesWithPrimary(rsp) {
+		t.Error("Expected the cookie to be reissued with the primary key")
+	}
+	newCookie := rsp.Header()["Set-Cookie"][0]
+
+	// Saving writes the cookie once.
+	rsp = serve(oldCookie, func(w http.ResponseWriter, r *http.Request) {
+		s, err := store.Get(r, "session-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Save(r, w); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !decodesWithPrimary(rsp) {
+		t.Error("Expected Save to encode the cookie with the primary key")
+	}
+
+	// Cookies encoded with the primary key are left alone.
+	rsp = serve(newCookie, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := store.Get(r, "session-key"); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if cookies := rsp.Header()["Set-Cookie"]; len(cookies) != 0 {
+		t.Errorf("Expected no cookie for a primary cookie, got %v", cookies)
+	}
+
+	// Flushing sends the headers, so the cookie is reissued first.
+	rsp = serve(oldCookie, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := store.Get(r, "session-key"); err != nil {
+			t.Fatal(err)
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("Expected the writer to implement http.Flusher")
+		}
+		flusher.Flush()
+		if cookies := w.Header()["Set-Cookie"]; len(cookies) != 1 {
+			t.Errorf("Expected the cookie to be reissued before flushing, got %v", cookies)
+		}
+	})
+	if !rsp.Flushed || !decodesWithPrimary(rsp) {
+		t.Error("Expected the flush to reach the recorder with the reissued cookie")
+	}
+
+	// Hijacking is forwarded when supported, and drops pending cookies.
+	rsp = serve(oldCookie, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := store.Get(r, "session-key"); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := w.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("Expected http.ErrNotSupported, got %v", err)
+		}
+	})
+	if !decodesWithPrimary(rsp) {
+		t.Error("Expected the cookie to be reissued after a failed hijack")
+	}
+	req, _ = http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	req.Header.Add("Cookie", oldCookie)
+	hijacked := &hijackRecorder{ResponseRecorder: NewRecorder()}
+	store.ReissueCookies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := store.Get(r, "session-key"); err != nil {
+			t.Fatal(err)
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	})).ServeHTTP(hijacked, req)
+	if !hijacked.hijacked {
+		t.Error("Expected Hijack to reach the underlying writer")
+	}
+	if cookies := hijacked.Header()["Set-Cookie"]; len(cookies) != 0 {
+		t.Errorf("Expected no cookie after hijacking, got %v", cookies)
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that supports http.Hijacker.
+type hijackRecorder struct {
+	*ResponseRecorder
+	hijacked bool
+}
+
+func (rw *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rw.hijacked = true
+	server, client := net.Pipe()
+	if err := client.Close(); err != nil {
+		return nil, nil, err
+	}
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+// WithScanCount sets the COUNT hint passed to SCAN when walking the
+// sessions of the store, i.e. roughly how many keys are fetched per round
+// trip. Default is 100.
+func WithScanCount(count int) Option {
+	return func(cfg *storeConfig) error {
+		if count <= 0 {
+			return fmt.Errorf("scan count must be positive, got %d", count)
+		}
+		cfg.scanCount = count
+		return nil
+	}
+}
+
+// globEscaper escapes the characters that have a meaning in SCAN MATCH.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// matchPattern returns the SCAN MATCH pattern selecting keys that start
+// with prefix.
+func matchPattern(prefix string) string {
+	return globEscaper.Replace(prefix) + "*"
+}
+
+// newStoredSession returns an empty session with the store's default
+// options for a session ID read from Redis rather than from a request.
+// The cookie name is not known, so the session name is empty.
+func (s *RediStore) newStoredSession(id string) *sessions.Session {
+	session := sessions.NewSession(s, "")
+	options := *s.Options
+	session.Options = &options
+	session.ID = id
+	return session
+}
+
+// scanPage runs a single SCAN over the store's key prefix starting at
+// cursor, and returns the next cursor and the keys found.
+func (s *RediStore) scanPage(conn redis.Conn, cursor uint64, count int) (uint64, []string, error) {
+	reply, err := redis.Values(conn.Do(
+		"SCAN", cursor, "MATCH", matchPattern(s.keyPrefix), "COUNT", count))
+	if err != nil {
+		return 0, nil, err
+	}
+	var keys []string
+	if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+		return 0, nil, err
+	}
+	return cursor, keys, nil
+}
+
+// Sessions returns an iterator over the IDs and decoded sessions stored
+// under the store's key prefix, and a function reporting the error that
+// ended the iteration, if any.
+//
+// The keyspace is walked with SCAN MATCH, never KEYS, fetching about
+// WithScanCount keys per round trip, so Redis is not blocked. As with
+// SCAN, a session may be seen more than once, and sessions created or
+// deleted during the walk may or may not be seen. Sessions that expire
+// between SCAN and the read are skipped. Sessions are created with the
+// store's default options and an empty name.
+//
+// Iteration stops when ctx is done or Redis fails. Sessions that cannot be
+// deserialized are skipped and their errors joined in the reported error.
+//
+// Example:
+//
+//	seq, errFn := store.Sessions(ctx)
+//	for id, session := range seq {
+//	    fmt.Println(id, session.Values)
+//	}
+//	if err := errFn(); err != nil {
+//	    log.Println(err)
+//	}
+func (s *RediStore) Sessions(ctx context.Context) (iter.Seq2[string, *sessions.Session], func() error) {
+	var errs []error
+	seq := func(yield func(string, *sessions.Session) bool) {
+		errs = errs[:0]
+		var cursor uint64
+		for {
+			if err := ctx.Err(); err != nil {
+				errs = append(errs, err)
+				return
+			}
+			next, page, err := s.sessionsPage(cursor)
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for _, r := range page {
+				if r.Err != nil {
+					errs = append(errs, fmt.Errorf("session %s: %w", r.Session.ID, r.Err))
+					continue
+				}
+				if !yield(r.Session.ID, r.Session) {
+					return
+				}
+			}
+			if next == 0 {
+				return
+			}
+			cursor = next
+		}
+	}
+	return seq, func() error { return errors.Join(errs...) }
+}
+
+// SessionIDs returns an iterator over the IDs of the sessions stored under
+// the store's key prefix and their remaining TTL, 0 for sessions without
+// one, and a function reporting the error that ended the iteration, if
+// any. Unlike Sessions it never reads payloads, so it also lists sessions
+// that cannot be deserialized, and costs a single pipelined round trip
+// per SCAN page.
+//
+// The keyspace is walked as by Sessions, with the same guarantees.
+//
+// Example:
+//
+//	seq, errFn := store.SessionIDs(ctx)
+//	for id, ttl := range seq {
+//	    fmt.Println(id, ttl)
+//	}
+//	if err := errFn(); err != nil {
+//	    log.Println(err)
+//	}
+func (s *RediStore) SessionIDs(ctx context.Context) (iter.Seq2[string, time.Duration], func() error) {
+	var err error
+	seq := func(yield func(string, time.Duration) bool) {
+		err = nil
+		var cursor uint64
+		for {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			var (
+				next uint64
+				page []adminSession
+			)
+			next, page, err = s.listPage(cursor, s.scanCount)
+			if err != nil {
+				return
+			}
+			for _, p := range page {
+				ttl := time.Duration(max(p.TTL, 0)) * time.Second
+				if !yield(p.ID, ttl) {
+					return
+				}
+			}
+			if next == 0 {
+				return
+			}
+			cursor = next
+		}
+	}
+	return seq, func() error { return err }
+}
+
+// sessionsPage reads one SCAN page of sessions starting at cursor. Keys
+// that expired between SCAN and MGET are left out.
+func (s *RediStore) sessionsPage(cursor uint64) (uint64, []BatchResult, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return 0, nil, err
+	}
+	next, keys, err := s.scanPage(conn, cursor, s.scanCount)
+	if err != nil || len(keys) == 0 {
+		return next, nil, err
+	}
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	values, err := redis.Values(conn.Do("MGET", args...))
+	if err != nil {
+		return 0, nil, err
+	}
+	page := make([]BatchResult, 0, len(keys))
+	for i, key := range keys {
+		if values[i] == nil {
+			continue
+		}
+		session := s.newStoredSession(strings.TrimPrefix(key, s.keyPrefix))
+		r := BatchResult{Session: session, Found: true}
+		b, err := redis.Bytes(values[i], nil)
+		if err == nil {
+			err = s.serializer.Deserialize(b, session)
+			restoreMaxAge(session)
+		}
+		r.Err = err
+		page = append(page, r)
+	}
+	return next, page, nil
+}
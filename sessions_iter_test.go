@@ -0,0 +1,145 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestSessionsIterator(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("iter*"+newSessionID()+"_"),
+		WithScanCount(2),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	want := make(map[string]int)
+	for i := 0; i < 5; i++ {
+		session := sessions.NewSession(store, "iter")
+		session.Options = &sessions.Options{MaxAge: 60}
+		session.ID = newSessionID()
+		session.Values["n"] = i
+		if err := store.save(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+		want[session.ID] = i
+	}
+
+	seq, errFn := store.Sessions(context.Background())
+	got := make(map[string]int)
+	for id, session := range seq {
+		got[id] = session.Values["n"].(int)
+	}
+	if err := errFn(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d sessions, got %d", len(want), len(got))
+	}
+	for id, n := range want {
+		if got[id] != n {
+			t.Errorf("Session %s: expected %d, got %d", id, n, got[id])
+		}
+	}
+
+	// Breaking out of the loop stops the scan.
+	count := 0
+	for range seq {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("Expected to stop after 1 session, got %d", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	seq, errFn = store.Sessions(ctx)
+	for range seq {
+		t.Fatal("Expected no sessions with a cancelled context")
+	}
+	if errFn() == nil {
+		t.Error("Expected context error")
+	}
+}
+
+func TestSessionIDs(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("ids_"+newSessionID()+"_"),
+		WithScanCount(2),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	want := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		session := sessions.NewSession(store, "ids")
+		session.Options = &sessions.Options{MaxAge: 60}
+		session.ID = newSessionID()
+		if err := store.save(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+		want[session.ID] = true
+	}
+	// A payload that can't be deserialized is still listed and deleted.
+	garbage := newSessionID()
+	conn := store.Pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("SETEX", store.sessionKey(garbage), 60, "not gob"); err != nil {
+		t.Fatal(err)
+	}
+	want[garbage] = true
+
+	seq, errFn := store.SessionIDs(context.Background())
+	got := 0
+	for id, ttl := range seq {
+		if !want[id] {
+			t.Errorf("Unexpected session %s", id)
+		}
+		if ttl <= 0 || ttl > time.Minute {
+			t.Errorf("Session %s: unexpected TTL %v", id, ttl)
+		}
+		got++
+	}
+	if err := errFn(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != len(want) {
+		t.Errorf("Expected %d sessions, got %d", len(want), got)
+	}
+
+	if err := store.DeleteByID(garbage); err != nil {
+		t.Fatalf("Expected undecodable session to be deleted, got %v", err)
+	}
+	if err := store.DeleteByID(garbage); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	if got := matchPattern("a*b?[c]\\_"); got != `a\*b\?\[c\]\\_*` {
+		t.Errorf("Unexpected pattern %q", got)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultStatsRate is the default number of keys per second examined by
+// Stats.
+const defaultStatsRate = 2000
+
+// statsPercentiles are the size percentiles reported by Stats.
+var statsPercentiles = []float64{50, 90, 99, 100}
+
+// statsTTLBounds are the upper bounds of the TTL buckets reported by Stats.
+// A last bucket holds the longer TTLs.
+var statsTTLBounds = []time.Duration{
+	time.Minute,
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+}
+
+// Stats describes the sessions of a store, as reported by RediStore.Stats.
+type Stats struct {
+	// Count is the number of sessions examined.
+	Count int
+
+	// Sampled is true if Stats stopped at the sample size before walking
+	// the whole key prefix, in which case the figures describe the sample.
+	Sampled bool
+
+	// TotalBytes is the sum of the serialized sizes of the sessions.
+	TotalBytes int64
+
+	// MaxLength is the store's maximum serialized size, 0 if unlimited.
+	MaxLength int
+
+	// Sizes are the percentiles of the serialized sizes.
+	Sizes []SizePercentile
+
+	// TTLs counts the sessions by remaining time to live.
+	TTLs []TTLBucket
+
+	// NoExpiry is the number of sessions without an expiry.
+	NoExpiry int
+
+	// Skipped is the number of keys under the key prefix that were left
+	// out because they don't hold a string, so can't be sessions.
+	Skipped int
+}
+
+// SizePercentile is a percentile of the serialized session sizes.
+type SizePercentile struct {
+	// Percentile is between 0 and 100; 100 is the largest session.
+	Percentile float64
+
+	// Bytes is the size at the percentile.
+	Bytes int
+
+	// OfMaxLength is Bytes as a fraction of the store's maxLength, or 0 if
+	// the length is unlimited. Values close to 1 mean sessions are close
+	// to being rejected as too big.
+	OfMaxLength float64
+}
+
+// TTLBucket counts the sessions whose remaining time to live is less than
+// Max, and at least the Max of the previous bucket. The Max of the last
+// bucket is 0, meaning unbounded.
+type TTLBucket struct {
+	Max   time.Duration
+	Count int
+}
+
+// StatsOption configures a call to RediStore.Stats.
+type StatsOption func(*statsConfig)
+
+type statsConfig struct {
+	sample int
+	rate   int
+}
+
+// WithStatsSample stops Stats after examining n sessions rather than
+// walking the whole key prefix. Since SCAN returns keys in hash order the
+// sample is spread over the keyspace. Default is 0, a full scan.
+func WithStatsSample(n int) StatsOption {
+	return func(cfg *statsConfig) {
+		cfg.sample = n
+	}
+}
+
+// WithStatsRate limits the number of sessions Stats examines per second,
+// to bound the load it puts on Redis. Default is 2000.
+func WithStatsRate(keysPerSecond int) StatsOption {
+	return func(cfg *statsConfig) {
+		cfg.rate = keysPerSecond
+	}
+}
+
+// Stats reports the number of sessions, their total and percentile sizes
+// and their TTL distribution. It walks the key prefix with SCAN, fetching
+// the size and TTL of every key without reading payloads, and paces
+// itself to the rate set by WithStatsRate so that it is safe to run
+// against a production server.
+//
+// Example:
+//
+//	stats, err := store.Stats(ctx, redistore.WithStatsSample(10000))
+func (s *RediStore) Stats(ctx context.Context, opts ...StatsOption) (*Stats, error) {
+	cfg := statsConfig{rate: defaultStatsRate}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.sample < 0 {
+		return nil, fmt.Errorf("stats sample size cannot be negative, got %d", cfg.sample)
+	}
+	if cfg.rate <= 0 {
+		return nil, errors.New("stats rate must be positive")
+	}
+
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{MaxLength: s.maxLength, TTLs: make([]TTLBucket, len(statsTTLBounds)+1)}
+	for i, bound := range statsTTLBounds {
+		stats.TTLs[i].Max = bound
+	}
+	var (
+		sizes  []int
+		cursor uint64
+		done   bool
+		start  = time.Now()
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		next, keys, err := s.scanPage(conn, cursor, s.scanCount)
+		if err != nil {
+			return nil, err
+		}
+		cursor = next
+		if cfg.sample > 0 && len(sizes)+len(keys) >= cfg.sample {
+			remaining := cfg.sample - len(sizes)
+			stats.Sampled = len(keys) > remaining || cursor != 0
+			keys = keys[:remaining]
+			done = true
+		}
+		if sizes, err = s.statKeys(conn, keys, stats, sizes); err != nil {
+			return nil, err
+		}
+		if done || cursor == 0 {
+			break
+		}
+		// Pace the walk: by now, len(sizes) keys should have taken at
+		// least len(sizes)/rate seconds.
+		due := start.Add(time.Duration(len(sizes)) * time.Second / time.Duration(cfg.rate))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	stats.Count = len(sizes)
+	if len(sizes) == 0 {
+		return stats, nil
+	}
+	slices.Sort(sizes)
+	for _, p := range statsPercentiles {
+		i := int(math.Ceil(p/100*float64(len(sizes)))) - 1
+		sp := SizePercentile{Percentile: p, Bytes: sizes[max(i, 0)]}
+		if s.maxLength > 0 {
+			sp.OfMaxLength = float64(sp.Bytes) / float64(s.maxLength)
+		}
+		stats.Sizes = append(stats.Sizes, sp)
+	}
+	return stats, nil
+}
+
+// statKeys fetches the size and TTL of keys in one pipeline, adds them to
+// stats and returns sizes with the sizes of the live keys appended. Keys
+// Redis can't report a size for, such as keys of another type, are
+// counted in stats.Skipped.
+func (s *RediStore) statKeys(conn redis.Conn, keys []string, stats *Stats, sizes []int) ([]int, error) {
+	for _, key := range keys {
+		if err := conn.Send("STRLEN", key); err != nil {
+			return nil, err
+		}
+		if err := conn.Send("PTTL", key); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	for range keys {
+		size, sizeErr := redis.Int(conn.Receive())
+		ms, err := redis.Int64(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		if sizeErr != nil {
+			if _, ok := sizeErr.(redis.Error); !ok {
+				return nil, sizeErr
+			}
+			stats.Skipped++
+			continue
+		}
+		if ms == -2 {
+			continue // expired since SCAN
+		}
+		sizes = append(sizes, size)
+		stats.TotalBytes += int64(size)
+		if ms == -1 {
+			stats.NoExpiry++
+			continue
+		}
+		ttl := time.Duration(ms) * time.Millisecond
+		i, _ := slices.BinarySearchFunc(statsTTLBounds, ttl, func(bound, ttl time.Duration) int {
+			if bound <= ttl {
+				return -1
+			}
+			return 1
+		})
+		stats.TTLs[i].Count++
+	}
+	return sizes, nil
+}
@@ -0,0 +1,110 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestStats(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("stats_"+newSessionID()[:8]+"_"),
+		WithScanCount(2),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+
+	ctx := context.Background()
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Count != 0 || stats.Sizes != nil {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+
+	for i, maxAge := range []int{30, 30, 7200, 7200, 7200} {
+		session := sessions.NewSession(store, "session-key")
+		session.Options = &sessions.Options{MaxAge: maxAge}
+		session.ID = newSessionID()
+		session.Values["data"] = make([]byte, i*100)
+		if err := store.save(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err = store.Stats(ctx, WithStatsRate(1000))
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Count != 5 || stats.Sampled {
+		t.Errorf("Expected 5 sessions in a full scan, got %d (sampled %v)", stats.Count, stats.Sampled)
+	}
+	if stats.TotalBytes <= 1000 {
+		t.Errorf("Expected more than 1000 bytes, got %d", stats.TotalBytes)
+	}
+	if len(stats.Sizes) != 4 {
+		t.Fatalf("Expected 4 percentiles, got %v", stats.Sizes)
+	}
+	largest := stats.Sizes[3]
+	if largest.Percentile != 100 || largest.Bytes <= 400 {
+		t.Errorf("Expected the largest session over 400 bytes, got %+v", largest)
+	}
+	if want := float64(largest.Bytes) / 4096; largest.OfMaxLength != want {
+		t.Errorf("Expected %v of maxLength, got %v", want, largest.OfMaxLength)
+	}
+	if stats.TTLs[0].Max != time.Minute || stats.TTLs[0].Count != 2 {
+		t.Errorf("Expected 2 sessions under a minute, got %+v", stats.TTLs[0])
+	}
+	if stats.TTLs[2].Count != 3 {
+		t.Errorf("Expected 3 sessions under a day, got %+v", stats.TTLs[2])
+	}
+
+	stats, err = store.Stats(ctx, WithStatsSample(3))
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Count != 3 || !stats.Sampled {
+		t.Errorf("Expected a sample of 3, got %d (sampled %v)", stats.Count, stats.Sampled)
+	}
+
+	// Keys of another type under the prefix are skipped, not fatal.
+	conn := store.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if _, err := conn.Do("HSET", store.keyPrefix+"not-a-session", "field", "value"); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = store.Stats(ctx, WithStatsRate(1000))
+	if err != nil {
+		t.Fatalf("Stats failed with a non-string key: %v", err)
+	}
+	if stats.Count != 5 || stats.Skipped != 1 {
+		t.Errorf("Expected 5 sessions and 1 skipped key, got %d and %d", stats.Count, stats.Skipped)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.Stats(ctx); err != context.Canceled {
+		t.Errorf("Expected Canceled, got %v", err)
+	}
+
+	if _, err := store.Stats(context.Background(), WithStatsRate(0)); err == nil {
+		t.Error("Expected error for zero rate")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// OpPing is the operation of the spans around the connection check of
+// NewStore. It is only reported to a Tracer.
+const OpPing Operation = "ping"
+
+// SpanAttributes describe the Redis round trip of a span.
+type SpanAttributes struct {
+	Command     string // Redis command, such as "GET" or "EVALSHA"
+	KeyPrefix   string // key prefix of the session, empty for ping
+	SessionName string // session (cookie) name, empty for ping
+}
+
+// Tracer starts a span around each Redis round trip of load, save, delete
+// and ping. ctx is the context of the request, so that spans nest inside
+// the server span of the request; it is context.Background() for calls
+// without a request. Implementations adapt it to a tracing SDK.
+type Tracer interface {
+	StartSpan(ctx context.Context, op Operation, attrs SpanAttributes) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// Finish ends the span with the size of the payload read or written,
+	// and the error of the round trip, if any.
+	Finish(bytes int, err error)
+}
+
+// WithTracer starts a span with tracer around every Redis round trip of
+// session loads, saves and deletes, and of the connection check of
+// NewStore. Loads served by the local cache have no span.
+//
+// Example:
+//
+//	WithTracer(otelAdapter{tracer: otel.Tracer("redistore")})
+func WithTracer(tracer Tracer) Option {
+	return func(cfg *storeConfig) error {
+		if tracer == nil {
+			return errors.New("tracer cannot be nil")
+		}
+		cfg.tracer = tracer
+		return nil
+	}
+}
+
+// noopSpan is the span of stores without a Tracer.
+type noopSpan struct{}
+
+func (noopSpan) Finish(int, error) {}
+
+// startSpan starts a span with the store's tracer, if any.
+func (s *RediStore) startSpan(ctx context.Context, op Operation, attrs SpanAttributes) Span {
+	if s.tracer == nil {
+		return noopSpan{}
+	}
+	return s.tracer.StartSpan(ctx, op, attrs)
+}
+
+// RecordedSpan is a span recorded by MemoryTracer.
+type RecordedSpan struct {
+	Op         Operation
+	Attributes SpanAttributes
+	Context    context.Context // context the span was started with
+	Start      time.Time
+	Duration   time.Duration
+	Bytes      int
+	Err        error
+}
+
+// MemoryTracer is a Tracer recording finished spans in memory, to check
+// instrumentation in tests. It is safe for concurrent use.
+type MemoryTracer struct {
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+// NewMemoryTracer returns an empty MemoryTracer.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+// StartSpan starts a span recorded when it finishes.
+func (t *MemoryTracer) StartSpan(ctx context.Context, op Operation, attrs SpanAttributes) Span {
+	return &memorySpan{
+		tracer: t,
+		span:   RecordedSpan{Op: op, Attributes: attrs, Context: ctx, Start: time.Now()},
+	}
+}
+
+// Spans returns the finished spans, in the order they finished.
+func (t *MemoryTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RecordedSpan(nil), t.spans...)
+}
+
+// Reset forgets the recorded spans.
+func (t *MemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = nil
+}
+
+type memorySpan struct {
+	tracer *MemoryTracer
+	span   RecordedSpan
+	once   sync.Once
+}
+
+func (s *memorySpan) Finish(bytes int, err error) {
+	s.once.Do(func() {
+		s.span.Duration = time.Since(s.span.Start)
+		s.span.Bytes = bytes
+		s.span.Err = err
+		s.tracer.mu.Lock()
+		defer s.tracer.mu.Unlock()
+		s.tracer.spans = append(s.tracer.spans, s.span)
+	})
+}
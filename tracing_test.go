@@ -0,0 +1,123 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type traceKey struct{}
+
+func TestTracer(t *testing.T) {
+	tracer := NewMemoryTracer()
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("app_"),
+		WithTracer(tracer),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	last := func(op Operation, command string) RecordedSpan {
+		spans := tracer.Spans()
+		tracer.Reset()
+		if len(spans) != 1 {
+			t.Fatalf("Expected one span, got %+v", spans)
+		}
+		span := spans[0]
+		if span.Op != op || span.Attributes.Command != command {
+			t.Errorf("Expected a %s span with %s, got %+v", op, command, span)
+		}
+		return span
+	}
+
+	if span := last(OpPing, "PING"); span.Err != nil || span.Attributes.KeyPrefix != "" {
+		t.Errorf("Unexpected ping span %+v", span)
+	}
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "server-span")
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/", nil)
+	rsp := NewRecorder()
+	session, err := store.New(req, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spans := tracer.Spans(); len(spans) != 0 {
+		t.Errorf("Expected no span for a request without cookie, got %+v", spans)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	span := last(OpSave, "SETEX")
+	if span.Attributes.KeyPrefix != "app_" || span.Attributes.SessionName != "session-key" {
+		t.Errorf("Unexpected save attributes %+v", span.Attributes)
+	}
+	if span.Bytes == 0 || span.Err != nil {
+		t.Errorf("Unexpected save span %+v", span)
+	}
+	if span.Context.Value(traceKey{}) != "server-span" {
+		t.Error("Expected the save span to be started with the request context")
+	}
+	size := span.Bytes
+
+	req.Header.Add("Cookie", getCookies(t, rsp)[0])
+	if _, err := store.New(req, "session-key"); err != nil {
+		t.Fatal(err)
+	}
+	span = last(OpLoad, "GET")
+	if span.Bytes != size || span.Err != nil || span.Context.Value(traceKey{}) != "server-span" {
+		t.Errorf("Unexpected load span %+v", span)
+	}
+
+	session.Options.MaxAge = -1
+	if err := session.Save(req, NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if span := last(OpDelete, "DEL"); span.Bytes != 0 || span.Attributes.KeyPrefix != "app_" {
+		t.Errorf("Unexpected delete span %+v", span)
+	}
+}
+
+func TestTracer_Error(t *testing.T) {
+	tracer := NewMemoryTracer()
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithTracer(tracer),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tracer.Reset()
+
+	if _, err := store.ping(context.Background()); err == nil {
+		t.Fatal("Expected ping on a closed store to fail")
+	}
+	spans := tracer.Spans()
+	if len(spans) != 1 || spans[0].Op != OpPing || spans[0].Err == nil {
+		t.Fatalf("Expected a failed ping span, got %+v", spans)
+	}
+	if spans[0].Duration < 0 || spans[0].Start.After(time.Now()) {
+		t.Errorf("Unexpected span timing %+v", spans[0])
+	}
+}
+
+func TestWithTracer_Nil(t *testing.T) {
+	if _, err := NewStore([][]byte{[]byte(testHashKey)}, WithTracer(nil)); err == nil {
+		t.Error("Expected an error for a nil tracer")
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright 2012 Brian "bojo" Jones. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package redistore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+// userValueKey is the session.Values key holding the principal bound by
+// BindUser, in the manner of the "_flash" key used by gorilla/sessions.
+const userValueKey = "_redistore_user"
+
+// SessionLimitPolicy decides what happens when a session is bound to a
+// user who already has the maximum number of sessions.
+type SessionLimitPolicy int
+
+const (
+	// EvictOldest deletes the user's oldest sessions, by creation time, to
+	// make room for the new one.
+	EvictOldest SessionLimitPolicy = iota
+
+	// RejectNew refuses to save the new session with ErrTooManySessions.
+	RejectNew
+)
+
+// ErrTooManySessions is returned by Save and RegenerateID when a session is
+// bound to a user who already has the maximum number of sessions and the
+// RejectNew policy is in effect.
+var ErrTooManySessions = errors.New("redistore: too many sessions for user")
+
+// WithMaxSessionsPerUser limits the number of live sessions bound to the
+// same user with BindUser. When a new session would exceed the limit,
+// policy either evicts the oldest sessions or rejects the new one.
+// Default is 0, no limit.
+//
+// Example:
+//
+//	WithMaxSessionsPerUser(3, EvictOldest)
+func WithMaxSessionsPerUser(n int, policy SessionLimitPolicy) Option {
+	return func(cfg *storeConfig) error {
+		if n < 0 {
+			return fmt.Errorf("max sessions per user cannot be negative, got %d", n)
+		}
+		if policy != EvictOldest && policy != RejectNew {
+			return fmt.Errorf("unknown session limit policy %d", policy)
+		}
+		cfg.maxUserSessions = n
+		cfg.userSessionPolicy = policy
+		return nil
+	}
+}
+
+// WithEvictionHandler sets a function called with the user and the IDs of
+// the sessions evicted by WithMaxSessionsPerUser. It is called
+// synchronously from Save, after the sessions have been deleted.
+func WithEvictionHandler(fn func(uid string, evicted []string)) Option {
+	return func(cfg *storeConfig) error {
+		if fn == nil {
+			return errors.New("eviction handler cannot be nil")
+		}
+		cfg.onEvict = fn
+		return nil
+	}
+}
+
+// enforceLimitLua defines enforceLimit, shared by the scripts that add a
+// session to a user's index. enforceLimit(index, created, id, now, limit,
+// reject, prefix, metaPrefix) prunes the index, and if adding id would
+// exceed limit, either evicts the oldest sessions, with their metadata, or
+// refuses id. It returns {"ok", evicted IDs...} or {"rejected"}.
+//
+// The index is a sorted set of session IDs scored by expiry time, so that
+// expired sessions can be pruned without touching their keys. A second
+// sorted set scores the same IDs by creation time, in milliseconds, to
+// find the oldest sessions. Both expire with the last session. Before the
+// limit is checked, entries whose sessions were deleted without going
+// through the index, by Purge or an external DEL, are pruned as well.
+const enforceLimitLua = `
+local function enforceLimit(index, created, id, now, limit, reject, prefix, metaPrefix)
+	local expired = redis.call('ZRANGEBYSCORE', index, '-inf', now)
+	for _, old in ipairs(expired) do
+		redis.call('ZREM', index, old)
+		redis.call('ZREM', created, old)
+	end
+	local result = {'ok'}
+	if limit == 0 or redis.call('ZSCORE', index, id) then
+		return result
+	end
+	for _, old in ipairs(redis.call('ZRANGE', index, 0, -1)) do
+		if redis.call('EXISTS', prefix .. old) == 0 then
+			redis.call('ZREM', index, old)
+			redis.call('ZREM', created, old)
+		end
+	end
+	local excess = redis.call('ZCARD', index) + 1 - limit
+	if excess <= 0 then
+		return result
+	end
+	if reject then
+		return {'rejected'}
+	end
+	for _, old in ipairs(redis.call('ZRANGE', created, 0, excess - 1)) do
+		redis.call('DEL', prefix .. old, metaPrefix .. old)
+		redis.call('ZREM', index, old)
+		redis.call('ZREM', created, old)
+		table.insert(result, old)
+	end
+	return result
+end
+`
+
+// saveBoundScript stores a session bound to a user and records it in the
+// user's index atomically, enforcing the per-user session limit, see
+// enforceLimitLua.
+//
+// The script returns {"ok", evicted IDs...} on success or {"rejected"} if
+// the session was refused.
+//
+// KEYS[1] - session key
+// KEYS[2] - user index key
+// KEYS[3] - user creation index key
+// ARGV[1] - session ID
+// ARGV[2] - TTL in seconds
+// ARGV[3] - payload
+// ARGV[4] - current unix time in milliseconds
+// ARGV[5] - maximum number of sessions, 0 for no limit
+// ARGV[6] - 1 to reject new sessions over the limit, 0 to evict
+// ARGV[7] - key prefix of indexed sessions
+// ARGV[8] - key prefix of the metadata of indexed sessions
+var saveBoundScript = redis.NewScript(3, enforceLimitLua+`
+local nowMs = tonumber(ARGV[4])
+local now = math.floor(nowMs / 1000)
+local ttl = tonumber(ARGV[2])
+local result = enforceLimit(KEYS[2], KEYS[3], ARGV[1], now, tonumber(ARGV[5]), ARGV[6] == '1', ARGV[7], ARGV[8])
+if result[1] == 'rejected' then
+	return result
+end
+redis.call('ZADD', KEYS[2], now + ttl, ARGV[1])
+if not redis.call('ZSCORE', KEYS[3], ARGV[1]) then
+	redis.call('ZADD', KEYS[3], nowMs, ARGV[1])
+end
+redis.call('SETEX', KEYS[1], ttl, ARGV[3])
+local last = redis.call('ZRANGE', KEYS[2], -1, -1, 'WITHSCORES')
+local expireAt = math.ceil(tonumber(last[2]))
+redis.call('EXPIREAT', KEYS[2], expireAt)
+redis.call('EXPIREAT', KEYS[3], expireAt)
+return result
+`)
+
+// userIndexKey returns the Redis key of the sorted set indexing the
+// sessions of uid by expiry. It deliberately doesn't start with keyPrefix
+// so that scanning the key prefix only yields sessions.
+func (s *RediStore) userIndexKey(uid string) string {
+	return "redistore:user:" + s.keyPrefix + uid
+}
+
+// userCreatedKey returns the Redis key of the sorted set indexing the
+// sessions of uid by creation time.
+func (s *RediStore) userCreatedKey(uid string) string {
+	return "redistore:user-created:" + s.keyPrefix + uid
+}
+
+// boundSaveArgs returns the saveBoundScript arguments for a session stored
+// under key.
+func (s *RediStore) boundSaveArgs(uid, key, id string, ttl int, b []byte) []interface{} {
+	args := []interface{}{
+		key, s.userIndexKey(uid), s.userCreatedKey(uid),
+		s.storedID(id), ttl, b, time.Now().UnixMilli(),
+	}
+	return append(args, s.userLimitArgs()...)
+}
+
+// userLimitArgs returns the limit, policy and key prefix arguments of
+// enforceLimit.
+func (s *RediStore) userLimitArgs() []interface{} {
+	reject := 0
+	if s.userSessionPolicy == RejectNew {
+		reject = 1
+	}
+	return []interface{}{s.maxUserSessions, reject, s.keyPrefix, metadataKeyPrefix + s.keyPrefix}
+}
+
+// boundSaveResult interprets the reply of a script calling enforceLimit. It drops evicted
+// sessions from the local cache, reports them to the eviction handler and
+// returns their keys so that the caller can publish their invalidation.
+func (s *RediStore) boundSaveResult(uid string, reply interface{}) ([]string, error) {
+	values, err := redis.Strings(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 || values[0] != "ok" {
+		return nil, ErrTooManySessions
+	}
+	evicted := values[1:]
+	if len(evicted) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(evicted))
+	for i, id := range evicted {
+		keys[i] = s.sessionKey(id)
+		s.cache.invalidate(keys[i])
+	}
+	if s.onEvict != nil {
+		s.onEvict(uid, evicted)
+	}
+	return keys, nil
+}
+
+// sendUnindex queues the removal of a session from the indexes of uid on
+// conn. It sends two commands.
+func (s *RediStore) sendUnindex(conn redis.Conn, uid, id string) error {
+	id = s.storedID(id)
+	if err := conn.Send("ZREM", s.userIndexKey(uid), id); err != nil {
+		return err
+	}
+	return conn.Send("ZREM", s.userCreatedKey(uid), id)
+}
+
+// UserID returns the principal bound to the session with BindUser, or an
+// empty string if there is none.
+func UserID(session *sessions.Session) string {
+	uid, _ := session.Values[userValueKey].(string)
+	return uid
+}
+
+// BindUser attaches the principal uid to the session. The session is
+// added to the user's index the next time it is saved, and stays there
+// until it is deleted or expires. Binding a session to a different user
+// removes it from the previous user's index immediately.
+//
+// Sessions without an ID are given one, so that the binding survives
+// until the session is saved. Sessions of a profile with its own key
+// prefix, see WithProfileKeyPrefix, cannot be bound, as the user's index
+// only tracks sessions under the store's key prefix.
+//
+// Example:
+//
+//	session, _ := store.Get(r, "session-key")
+//	if err := store.BindUser(session, "42"); err != nil {
+//	    return err
+//	}
+//	err = session.Save(r, w)
+func (s *RediStore) BindUser(session *sessions.Session, uid string) error {
+	if uid == "" {
+		return errors.New("user id cannot be empty")
+	}
+	if prefix := s.profile(session.Name()).keyPrefix; prefix != s.keyPrefix {
+		return fmt.Errorf("cannot bind %q sessions to a user: their key prefix %q is not the store's", session.Name(), prefix)
+	}
+	if session.ID == "" {
+		id, err := s.newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	if prev := UserID(session); prev != "" && prev != uid {
+		if err := s.unindexSession(prev, session.ID); err != nil {
+			return err
+		}
+	}
+	session.Values[userValueKey] = uid
+	return nil
+}
+
+// unindexSession removes a single session from the index of uid.
+func (s *RediStore) unindexSession(uid, id string) error {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := s.sendUnindex(conn, uid, id); err != nil {
+		return err
+	}
+	_, err := conn.Do("")
+	return err
+}
+
+// ListUserSessions returns the IDs of the live sessions bound to uid,
+// least recently saved first. Index entries whose sessions have expired
+// or were deleted are pruned along the way.
+func (s *RediStore) ListUserSessions(uid string) ([]string, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+	index := s.userIndexKey(uid)
+	expired, err := redis.Values(conn.Do("ZRANGEBYSCORE", index, "-inf", time.Now().Unix()))
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) > 0 {
+		for _, key := range []string{index, s.userCreatedKey(uid)} {
+			if err := conn.Send("ZREM", append([]interface{}{key}, expired...)...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ids, err := redis.Strings(conn.Do("ZRANGE", index, 0, -1))
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// Sessions deleted without going through this store, or before the
+	// index existed, are only detectable by checking their keys.
+	for _, id := range ids {
+		if err := conn.Send("EXISTS", s.sessionKey(id)); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	live := make([]string, 0, len(ids))
+	var stale []interface{}
+	for _, id := range ids {
+		exists, err := redis.Bool(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			live = append(live, id)
+		} else {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		for _, key := range []string{index, s.userCreatedKey(uid)} {
+			if err := conn.Send("ZREM", append([]interface{}{key}, stale...)...); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := conn.Do(""); err != nil {
+			return nil, err
+		}
+	}
+	return live, nil
+}
+
+// RevokeUserSessions deletes every session bound to uid, logging the user
+// out everywhere, and returns the number of sessions deleted.
+func (s *RediStore) RevokeUserSessions(uid string) (int, error) {
+	conn := s.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if err := conn.Err(); err != nil {
+		return 0, err
+	}
+	index := s.userIndexKey(uid)
+	ids, err := redis.Strings(conn.Do("ZRANGE", index, 0, -1))
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		_, err = conn.Do("DEL", index, s.userCreatedKey(uid))
+		return 0, err
+	}
+	keys := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = s.sessionKey(id)
+		args[i] = keys[i]
+	}
+	if err := conn.Send("DEL", args...); err != nil {
+		return 0, err
+	}
+	// Drop the index and the metadata of the sessions in the same command.
+	others := []interface{}{index, s.userCreatedKey(uid)}
+	for _, id := range ids {
+		others = append(others, s.metadataKey(id))
+	}
+	if err := conn.Send("DEL", others...); err != nil {
+		return 0, err
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+	n, err := redis.Int(conn.Receive())
+	if err != nil {
+		return 0, err
+	}
+	if _, err := conn.Receive(); err != nil {
+		return n, err
+	}
+	for _, key := range keys {
+		s.cache.invalidate(key)
+	}
+	return n, s.publishInvalidation(conn, keys...)
+}
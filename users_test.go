@@ -0,0 +1,248 @@
+package redistore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/sessions"
+)
+
+func TestUserSessionIndex(t *testing.T) {
+	addr := setup()
+	store := createTestStore(t, addr)
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	uid := "user-" + newSessionID()
+
+	// Log the user in from two devices.
+	ids := make([]string, 2)
+	for i := range ids {
+		req, _ := http.NewRequestWithContext(
+			context.Background(), "GET", "http://localhost:8080/", nil)
+		rsp := NewRecorder()
+		session, err := store.New(req, "session-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.BindUser(session, uid); err != nil {
+			t.Fatalf("BindUser failed: %v", err)
+		}
+		if UserID(session) != uid {
+			t.Errorf("Expected UserID %q, got %q", uid, UserID(session))
+		}
+		if err := session.Save(req, rsp); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		ids[i] = session.ID
+	}
+
+	listed, err := store.ListUserSessions(uid)
+	if err != nil {
+		t.Fatalf("ListUserSessions failed: %v", err)
+	}
+	slices.Sort(listed)
+	want := slices.Clone(ids)
+	slices.Sort(want)
+	if !slices.Equal(listed, want) {
+		t.Errorf("Expected %v, got %v", want, listed)
+	}
+
+	// Entries whose keys are gone or expired are pruned.
+	conn := store.Pool.Get()
+	defer conn.Close()
+	index := store.userIndexKey(uid)
+	if _, err := conn.Do("ZADD", index, time.Now().Unix()-1, "expired"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Do("ZADD", index, time.Now().Unix()+60, "deleted"); err != nil {
+		t.Fatal(err)
+	}
+	listed, err = store.ListUserSessions(uid)
+	if err != nil {
+		t.Fatalf("ListUserSessions failed: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Errorf("Expected stale entries to be pruned, got %v", listed)
+	}
+	if n, _ := conn.Do("ZCARD", index); n != int64(2) {
+		t.Errorf("Expected index to hold 2 entries, got %v", n)
+	}
+
+	n, err := store.RevokeUserSessions(uid)
+	if err != nil {
+		t.Fatalf("RevokeUserSessions failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 revoked sessions, got %d", n)
+	}
+	for _, id := range ids {
+		if exists, _ := conn.Do("EXISTS", store.keyPrefix+id); exists != int64(0) {
+			t.Errorf("Expected session %s to be deleted", id)
+		}
+	}
+	if listed, _ := store.ListUserSessions(uid); len(listed) != 0 {
+		t.Errorf("Expected no sessions after revocation, got %v", listed)
+	}
+}
+
+func TestBindUser_Empty(t *testing.T) {
+	store := &RediStore{}
+	session := sessions.NewSession(store, "session-key")
+	if err := store.BindUser(session, ""); err == nil {
+		t.Error("Expected error for empty user id")
+	}
+}
+
+func TestMaxSessionsPerUser(t *testing.T) {
+	addr := setup()
+	var evicted []string
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithMetadata(),
+		WithMaxSessionsPerUser(2, EvictOldest),
+		WithEvictionHandler(func(uid string, ids []string) {
+			evicted = append(evicted, ids...)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	uid := "user-" + newSessionID()
+
+	login := func() (*sessions.Session, error) {
+		session := sessions.NewSession(store, "session-key")
+		session.Options = &sessions.Options{MaxAge: 60}
+		if err := store.BindUser(session, uid); err != nil {
+			return nil, err
+		}
+		// Creation order is tracked with millisecond precision.
+		time.Sleep(2 * time.Millisecond)
+		return session, store.save(context.Background(), session)
+	}
+
+	first, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequestWithContext(
+		context.Background(), "GET", "http://localhost:8080/", nil)
+	if err := store.touchMetadata(req, first, -1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Metadata(first.ID); err != nil {
+		t.Fatalf("Metadata failed: %v", err)
+	}
+	second, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Saving an already bound session again must not count twice.
+	if err := store.save(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("Expected no evictions yet, got %v", evicted)
+	}
+	third, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(evicted, []string{first.ID}) {
+		t.Errorf("Expected %s to be evicted, got %v", first.ID, evicted)
+	}
+	conn := store.Pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}()
+	if n, err := redis.Int(conn.Do("EXISTS", store.metadataKey(first.ID))); err != nil || n != 0 {
+		t.Errorf("Expected the metadata of the evicted session to be deleted, got %d (%v)", n, err)
+	}
+	listed, err := store.ListUserSessions(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(listed)
+	want := []string{second.ID, third.ID}
+	slices.Sort(want)
+	if !slices.Equal(listed, want) {
+		t.Errorf("Expected %v, got %v", want, listed)
+	}
+
+	store.userSessionPolicy = RejectNew
+	if _, err := login(); err != ErrTooManySessions {
+		t.Errorf("Expected ErrTooManySessions, got %v", err)
+	}
+	if listed, _ := store.ListUserSessions(uid); len(listed) != 2 {
+		t.Errorf("Expected rejected session not to be indexed, got %v", listed)
+	}
+}
+
+func TestMaxSessionsPerUser_Purged(t *testing.T) {
+	addr := setup()
+	store, err := NewStore(
+		[][]byte{[]byte(testHashKey)},
+		WithAddress("tcp", addr),
+		WithKeyPrefix("purged_"+newSessionID()[:8]+"_"),
+		WithMaxSessionsPerUser(1, RejectNew),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			fmt.Printf("Error closing store: %v\n", err)
+		}
+	}()
+	uid := "user-" + newSessionID()
+	login := func() error {
+		session := sessions.NewSession(store, "session-key")
+		session.Options = &sessions.Options{MaxAge: 60}
+		if err := store.BindUser(session, uid); err != nil {
+			return err
+		}
+		return store.save(context.Background(), session)
+	}
+
+	if err := login(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Purge(context.Background(), ""); err != nil || n != 1 {
+		t.Fatalf("Expected 1 purged session, got %d, %v", n, err)
+	}
+	// The purged session is still indexed but must not count.
+	if err := login(); err != nil {
+		t.Fatalf("Expected the purged session to be pruned, got %v", err)
+	}
+	if err := login(); err != ErrTooManySessions {
+		t.Errorf("Expected ErrTooManySessions, got %v", err)
+	}
+}
+
+func TestWithMaxSessionsPerUser_Invalid(t *testing.T) {
+	cfg := defaultConfig()
+	if err := WithMaxSessionsPerUser(-1, EvictOldest)(cfg); err == nil {
+		t.Error("Expected error for negative limit")
+	}
+	if err := WithMaxSessionsPerUser(3, SessionLimitPolicy(42))(cfg); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+	if err := WithEvictionHandler(nil)(cfg); err == nil {
+		t.Error("Expected error for nil handler")
+	}
+}